@@ -1,12 +1,18 @@
 package jwtmock
 
 import (
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"time"
 
 	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/lestrrat-go/jwx/jws"
+	"github.com/lestrrat-go/jwx/jws/sign"
 	"github.com/lestrrat-go/jwx/jwt"
 
 	"github.com/mitchellh/mapstructure"
@@ -21,14 +27,47 @@ var (
 
 	// ErrSubMissing means the JWT token has missing subject
 	ErrSubMissing = errors.New("token subject is missing")
+
+	// ErrNoCertificates means the signing key has no certificate chain (e.g. it is a symmetric key).
+	ErrNoCertificates = errors.New("signing key has no certificates")
 )
 
+// DefaultTokenTTL is the default lifetime of JWTs without an "exp" claim.
+const DefaultTokenTTL = time.Hour
+
+// ClaimDefaults are used to fill the standard claims missing from JWT claims.
+type ClaimDefaults struct {
+	// TTL sets "exp" to the time the JWT is generated plus this lifetime - no "exp" is set if zero.
+	TTL time.Duration
+
+	// Issuer is set as the "iss" claim - if any.
+	Issuer string
+
+	// Audience is set as the "aud" claim - if any.
+	Audience []string
+
+	// Rand is the source of randomness for "jti" - crypto/rand if nil.
+	Rand io.Reader
+
+	// Now is the clock for "iat", "nbf" and "exp" - the system clock if nil.
+	Now func() time.Time
+}
+
 // SigningKey represents a generic key used to sign JWTs.
 type SigningKey struct {
 	ID        string
 	Key       interface{}
 	Algorithm jwa.SignatureAlgorithm
 	PublicKey interface{}
+
+	// Certificates is the certificate chain (leaf certificate first) published with the key - if any.
+	Certificates []*x509.Certificate
+}
+
+// IsSymmetric returns true if this key is a shared secret (e.g. for HMAC) rather than a private key.
+func (k *SigningKey) IsSymmetric() bool {
+	_, ok := k.Key.([]byte)
+	return ok
 }
 
 // Claims represents the type for JWT claims
@@ -49,8 +88,86 @@ type requiredClaims struct {
 	ExpiredAt int64  `mapstructure:"exp"`
 }
 
+// WithDefaults returns the claims with missing standard claims filled from the defaults - "iat" and "nbf" are the
+// current time, "exp" is the current time plus the TTL and "jti" is a random UUID. Claims with a nil value are removed
+// so that defaults can be suppressed.
+func (c Claims) WithDefaults(defaults ClaimDefaults) (Claims, error) {
+	now := time.Now()
+	if defaults.Now != nil {
+		now = defaults.Now()
+	}
+
+	// "nbf" follows the given "iat" if it is a number
+	issuedAt := now
+	if r := (requiredClaims{}); mapstructure.Decode(c, &r) == nil && r.IssuedAt != 0 {
+		issuedAt = time.Unix(r.IssuedAt, 0)
+	}
+
+	claims := Claims{
+		jwt.IssuedAtKey:  now.Unix(),
+		jwt.NotBeforeKey: issuedAt.Unix(),
+	}
+
+	if defaults.TTL > 0 {
+		claims[jwt.ExpirationKey] = now.Add(defaults.TTL).Unix()
+	}
+
+	random := defaults.Rand
+	if random == nil {
+		random = rand.Reader
+	}
+
+	jti, err := newUUID(random)
+	if err != nil {
+		return nil, fmt.Errorf("JWT ID: %w", err)
+	}
+
+	claims[jwt.JwtIDKey] = jti
+
+	if defaults.Issuer != "" {
+		claims[jwt.IssuerKey] = defaults.Issuer
+	}
+
+	switch len(defaults.Audience) {
+	case 0:
+	case 1:
+		claims[jwt.AudienceKey] = defaults.Audience[0]
+	default:
+		claims[jwt.AudienceKey] = defaults.Audience
+	}
+
+	for k, v := range c {
+		if v == nil {
+			delete(claims, k)
+			continue
+		}
+
+		claims[k] = v
+	}
+
+	return claims, nil
+}
+
+// newUUID returns a random (version 4) UUID read from the given source.
+func newUUID(random io.Reader) (string, error) {
+	b := make([]byte, 16)
+	if _, err := io.ReadFull(random, b); err != nil {
+		return "", err
+	}
+
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
 // Valid returns an error if this token is invalid
 func (c Claims) Valid() error {
+	return c.ValidAt(time.Now())
+}
+
+// ValidAt returns an error if this token is invalid at the given time - e.g. the fixed time of a test server.
+func (c Claims) ValidAt(now time.Time) error {
 	r := requiredClaims{}
 	err := mapstructure.Decode(c, &r)
 	if err != nil {
@@ -58,12 +175,12 @@ func (c Claims) Valid() error {
 	}
 
 	expiresAt := time.Unix(r.ExpiredAt, 0)
-	if time.Now().After(expiresAt) {
+	if now.After(expiresAt) {
 		return ErrExpiredToken
 	}
 
 	issuedAt := time.Unix(r.IssuedAt, 0)
-	if time.Now().Before(issuedAt) {
+	if now.Before(issuedAt) {
 		return ErrBadTokenFuture
 	}
 
@@ -74,28 +191,139 @@ func (c Claims) Valid() error {
 	return nil
 }
 
-// CreateJWT generates a JWT token using the provided claims and signing key.
-func (c Claims) CreateJWT(signingKey *SigningKey) (string, error) {
-	if err := c.Valid(); err != nil {
-		return "", fmt.Errorf("validation: %w", err)
+// CreateJWT generates a JWT token using the provided claims and signing key. The claims must be valid unless the
+// WithUncheckedClaims option is given. Token options are also used to set the "jku", "x5u", "x5c" and custom headers or
+// to generate a malformed or algorithm confusion JWT - options selecting the signing key are ignored.
+func (c Claims) CreateJWT(signingKey *SigningKey, options ...TokenOption) (string, error) {
+	opts := NewTokenOptions(options...)
+	if opts.Malformed != "" {
+		return c.CreateMalformedJWT(opts.Malformed, signingKey, options...)
 	}
 
-	headers := jws.NewHeaders()
-	if err := headers.Set(jws.KeyIDKey, signingKey.ID); err != nil {
-		return "", fmt.Errorf("JWS headers key: %w", err)
+	if opts.ConfusionEncoding != "" {
+		alg := opts.ConfusionAlgorithm
+		if alg == "" {
+			alg = jwa.HS256
+		}
+
+		return c.CreateConfusionJWT(signingKey, alg, opts.ConfusionEncoding, options...)
+	}
+
+	if !opts.Unchecked {
+		now := time.Now()
+		if opts.Now != nil {
+			now = opts.Now()
+		}
+
+		if err := c.ValidAt(now); err != nil {
+			return "", fmt.Errorf("validation: %w", err)
+		}
+	}
+
+	header, err := newHeader(signingKey, opts)
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := c.payload(opts.Unchecked)
+	if err != nil {
+		return "", err
+	}
+
+	return signJWT(header, base64.RawURLEncoding.EncodeToString(payload), signingKey.Algorithm, signingKey.Key)
+}
+
+// payload returns the JSON-encoded claims - standard claims must have valid types unless the claims are unchecked.
+func (c Claims) payload(unchecked bool) ([]byte, error) {
+	if unchecked {
+		payload, err := json.Marshal(c)
+		if err != nil {
+			return nil, fmt.Errorf("marshal claims: %w", err)
+		}
+
+		return payload, nil
 	}
 
 	token := jwt.New()
 	for k, v := range c {
 		if err := token.Set(k, v); err != nil {
-			return "", fmt.Errorf("set claim %v: %w", k, err)
+			return nil, fmt.Errorf("set claim %v: %w", k, err)
+		}
+	}
+
+	payload, err := json.Marshal(token)
+	if err != nil {
+		return nil, fmt.Errorf("marshal claims: %w", err)
+	}
+
+	return payload, nil
+}
+
+// newHeader returns the JOSE header for a JWT signed with the given key - custom headers override or (if nil)
+// suppress the defaults.
+func newHeader(signingKey *SigningKey, opts *TokenOptions) (map[string]interface{}, error) {
+	header := map[string]interface{}{
+		jws.AlgorithmKey: signingKey.Algorithm,
+		jws.TypeKey:      "JWT",
+		jws.KeyIDKey:     signingKey.ID,
+	}
+
+	if opts.KeySetURL != "" {
+		header[jws.JWKSetURLKey] = opts.KeySetURL
+	}
+
+	if opts.CertificateURL != "" {
+		header[jws.X509URLKey] = opts.CertificateURL
+	}
+
+	if opts.CertificateChain {
+		if len(signingKey.Certificates) == 0 {
+			return nil, ErrNoCertificates
+		}
+
+		// the chain is a list of base64-encoded (not base64url) DER certificates (RFC 7515 section 4.1.6)
+		chain := make([]string, 0, len(signingKey.Certificates))
+		for _, cert := range signingKey.Certificates {
+			chain = append(chain, base64.StdEncoding.EncodeToString(cert.Raw))
+		}
+
+		header[jws.X509CertChainKey] = chain
+	}
+
+	for k, v := range opts.Headers {
+		if v == nil {
+			delete(header, k)
+			continue
 		}
+
+		header[k] = v
+	}
+
+	return header, nil
+}
+
+// signJWT returns the compact serialization of a JWT with the given header and encoded payload, signed by the key.
+func signJWT(header map[string]interface{}, payloadSegment string, alg jwa.SignatureAlgorithm,
+	key interface{}) (string, error) {
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", fmt.Errorf("marshal header: %w", err)
+	}
+
+	return signInput(base64.RawURLEncoding.EncodeToString(headerJSON)+"."+payloadSegment, alg, key)
+}
+
+// signInput returns the signing input with the signature by the key appended.
+func signInput(signingInput string, alg jwa.SignatureAlgorithm, key interface{}) (string, error) {
+	signer, err := sign.New(alg)
+	if err != nil {
+		return "", fmt.Errorf("signer: %w", err)
 	}
 
-	signedToken, err := jwt.Sign(token, signingKey.Algorithm, signingKey.Key, jwt.WithHeaders(headers))
+	signature, err := signer.Sign([]byte(signingInput), key)
 	if err != nil {
 		return "", fmt.Errorf("sign: %w", err)
 	}
 
-	return string(signedToken), nil
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
 }
@@ -5,7 +5,12 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwa"
 )
 
 type jwtResponse struct {
@@ -23,8 +28,8 @@ type Client struct {
 type ClientOption func(*Client)
 
 // NewClient creates a new client with the base URL and given options
-func NewClient(url string, options ...ClientOption) *Client {
-	c := &Client{URL: url}
+func NewClient(baseURL string, options ...ClientOption) *Client {
+	c := &Client{URL: baseURL}
 	for _, option := range options {
 		option(c)
 	}
@@ -37,11 +42,57 @@ func NewClient(url string, options ...ClientOption) *Client {
 }
 
 // GenerateJWT generates a JWT token for use in authorization header.
-func (c *Client) GenerateJWT(ctx context.Context, claims Claims) (string, error) {
-	url := fmt.Sprintf("%v/jwtmock/generate-jwt", c.URL)
+// Use options to select the signing key, otherwise the server's current signing key is used.
+func (c *Client) GenerateJWT(ctx context.Context, claims Claims, options ...TokenOption) (string, error) {
+	opts := NewTokenOptions(options...)
+
+	query := url.Values{}
+	if opts.KeyID != "" {
+		query.Set("kid", opts.KeyID)
+	}
+
+	if opts.Algorithm != "" {
+		query.Set("alg", opts.Algorithm.String())
+	}
+
+	if opts.KeySetURL != "" {
+		query.Set("jku", opts.KeySetURL)
+	}
+
+	if opts.CertificateURL != "" {
+		query.Set("x5u", opts.CertificateURL)
+	}
+
+	if opts.CertificateChain {
+		query.Set("x5c", "true")
+	}
+
+	if opts.Unchecked {
+		query.Set("unchecked", "true")
+	}
+
+	if opts.Malformed != "" {
+		query.Set("malformed", string(opts.Malformed))
+	}
+
+	if opts.ConfusionEncoding != "" {
+		query.Set("confusion", string(opts.ConfusionEncoding))
+	}
+
+	if opts.ConfusionAlgorithm != "" {
+		query.Set("confusion_alg", opts.ConfusionAlgorithm.String())
+	}
+
+	reqURL := fmt.Sprintf("%v/jwtmock/generate-jwt", c.URL)
+	if len(query) > 0 {
+		reqURL = fmt.Sprintf("%v?%v", reqURL, query.Encode())
+	}
+
+	// the claims are always wrapped in a JWT request so that a "claims" or "headers" claim isn't mistaken for one
+	body := JWTRequest{Claims: claims, Headers: opts.Headers}
 
 	var jwtResp jwtResponse
-	err := c.jsonRequest(ctx, url, claims, http.StatusOK, &jwtResp)
+	err := c.jsonRequest(ctx, http.MethodPost, reqURL, body, http.StatusOK, &jwtResp)
 	if err != nil {
 		return "", err
 	}
@@ -49,11 +100,120 @@ func (c *Client) GenerateJWT(ctx context.Context, claims Claims) (string, error)
 	return jwtResp.Token, nil
 }
 
+// KeySetURL returns the URL of a JWKS containing only the key with the given ID - for use with WithKeySetURL.
+func (c *Client) KeySetURL(kid string) string {
+	return fmt.Sprintf("%v/jwtmock/jwks/%v", c.URL, url.PathEscape(kid))
+}
+
+// CertificateChainURL returns the "x5u" URL served for the key with the given ID - for use with WithCertificateURL.
+func (c *Client) CertificateChainURL(kid string) string {
+	return fmt.Sprintf("%v/jwtmock/x509/%v", c.URL, url.PathEscape(kid))
+}
+
 // RegisterClient register a new client
 func (c *Client) RegisterClient(ctx context.Context, registration ClientRegistration) error {
-	url := fmt.Sprintf("%v/jwtmock/clients", c.URL)
+	reqURL := fmt.Sprintf("%v/jwtmock/clients", c.URL)
+
+	return c.jsonRequest(ctx, http.MethodPost, reqURL, registration, http.StatusAccepted, nil)
+}
+
+// AddKey asks the server to generate and publish another signing key for the given algorithm (the server's default
+// algorithm if empty) - the current signing key stays the same.
+func (c *Client) AddKey(ctx context.Context, alg jwa.SignatureAlgorithm) (*KeyInfo, error) {
+	reqURL := fmt.Sprintf("%v/jwtmock/keys", c.URL)
+
+	var keyInfo KeyInfo
+	if err := c.jsonRequest(ctx, http.MethodPost, reqURL, KeyRequest{Algorithm: alg.String()},
+		http.StatusCreated, &keyInfo); err != nil {
+		return nil, err
+	}
+
+	return &keyInfo, nil
+}
 
-	return c.jsonRequest(ctx, url, registration, http.StatusAccepted, nil)
+// RotateKey replaces the current signing key with a new one. The previous key no longer signs JWTs but stays
+// published for the given retention - or until explicitly retired if the retention is zero. The server works in
+// whole seconds, so the retention is rounded up to the next second rather than down to zero.
+func (c *Client) RotateKey(ctx context.Context, retention time.Duration) (*KeyInfo, error) {
+	reqURL := fmt.Sprintf("%v/jwtmock/keys/rotate", c.URL)
+
+	retentionSeconds := int64(retention / time.Second)
+	if retention%time.Second > 0 {
+		retentionSeconds++
+	}
+
+	var keyInfo KeyInfo
+	if err := c.jsonRequest(ctx, http.MethodPost, reqURL, RotateRequest{RetentionSeconds: &retentionSeconds},
+		http.StatusCreated, &keyInfo); err != nil {
+		return nil, err
+	}
+
+	return &keyInfo, nil
+}
+
+// StageKey adds a new signing key which signs JWTs but is withheld from the JWKS - it is published after the
+// requested delay or by PublishKey.
+func (c *Client) StageKey(ctx context.Context, stage StageRequest) (*KeyInfo, error) {
+	reqURL := fmt.Sprintf("%v/jwtmock/keys/stage", c.URL)
+
+	var keyInfo KeyInfo
+	if err := c.jsonRequest(ctx, http.MethodPost, reqURL, stage, http.StatusCreated, &keyInfo); err != nil {
+		return nil, err
+	}
+
+	return &keyInfo, nil
+}
+
+// PublishKey asks the server to publish the staged key with the given ID ahead of its publish delay.
+func (c *Client) PublishKey(ctx context.Context, kid string) error {
+	reqURL := fmt.Sprintf("%v/jwtmock/keys/%v/publish", c.URL, url.PathEscape(kid))
+
+	return c.jsonRequest(ctx, http.MethodPost, reqURL, nil, http.StatusNoContent, nil)
+}
+
+// RetireKey asks the server to retire the key with the given ID - it is removed from the JWKS, so JWTs it signed no
+// longer verify.
+func (c *Client) RetireKey(ctx context.Context, kid string) error {
+	reqURL := fmt.Sprintf("%v/jwtmock/keys/%v", c.URL, url.PathEscape(kid))
+
+	return c.jsonRequest(ctx, http.MethodDelete, reqURL, nil, http.StatusNoContent, nil)
+}
+
+// ListKeys fetches the key listing of the server - the status of each key, including recently retired ones.
+func (c *Client) ListKeys(ctx context.Context) ([]KeyInfo, error) {
+	reqURL := fmt.Sprintf("%v/jwtmock/keys", c.URL)
+
+	var keys struct {
+		Keys []KeyInfo `json:"keys"`
+	}
+	if err := c.jsonRequest(ctx, http.MethodGet, reqURL, nil, http.StatusOK, &keys); err != nil {
+		return nil, err
+	}
+
+	return keys.Keys, nil
+}
+
+// DeactivateKey asks the server to stop signing JWTs with the key with the given ID without unpublishing it.
+func (c *Client) DeactivateKey(ctx context.Context, kid string) error {
+	reqURL := fmt.Sprintf("%v/jwtmock/keys/%v/signing", c.URL, url.PathEscape(kid))
+
+	return c.jsonRequest(ctx, http.MethodDelete, reqURL, nil, http.StatusNoContent, nil)
+}
+
+// ExportSigningKey returns the signing key with the given ID - or the server's current signing key if the ID is
+// empty - as PEM and as a private JWK. This fails unless private key export is enabled on the server.
+func (c *Client) ExportSigningKey(ctx context.Context, kid string) (*PrivateKeyExport, error) {
+	reqURL := fmt.Sprintf("%v/jwtmock/signing-key", c.URL)
+	if kid != "" {
+		reqURL = fmt.Sprintf("%v?%v", reqURL, url.Values{"kid": []string{kid}}.Encode())
+	}
+
+	var export PrivateKeyExport
+	if err := c.jsonRequest(ctx, http.MethodGet, reqURL, nil, http.StatusOK, &export); err != nil {
+		return nil, err
+	}
+
+	return &export, nil
 }
 
 // WithHTTPClient option is used to set the http client
@@ -63,17 +223,21 @@ func WithHTTPClient(hc *http.Client) ClientOption {
 	}
 }
 
-// jsonRequest sends a JSON request with expected status and an instance for populating response
-func (c *Client) jsonRequest(ctx context.Context, url string, reqBody interface{},
+// jsonRequest sends a JSON request with expected status and an instance for populating response.
+// The request has no body if reqBody is nil.
+func (c *Client) jsonRequest(ctx context.Context, method, reqURL string, reqBody interface{},
 	expectedStatus int, response interface{}) error {
-	claimsJSON, err := json.Marshal(reqBody)
-	if err != nil {
+	var body io.Reader = http.NoBody
+	if reqBody != nil {
+		claimsJSON, err := json.Marshal(reqBody)
 		if err != nil {
 			return fmt.Errorf("marshal JSON: %w", err)
 		}
+
+		body = bytes.NewReader(claimsJSON)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(claimsJSON))
+	req, err := http.NewRequestWithContext(ctx, method, reqURL, body)
 	if err != nil {
 		return fmt.Errorf("create reqBody: %w", err)
 	}
@@ -7,6 +7,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/nayyara-cropsey/jwtmock"
 	"gopkg.in/yaml.v2"
 )
 
@@ -16,16 +18,108 @@ const (
 	keyLenEnv   = "key_length"
 	certLifeEnv = "cert_life_days"
 	logLevelEnv = "log_level"
+	sigAlgEnv   = "signing_algorithm"
+	hmacEnv     = "hmac_secret"
+	pubHMACEnv  = "publish_hmac_key"
+	addKeysEnv  = "additional_keys"
+	retainEnv   = "key_retention_seconds"
+	rotateEnv   = "key_rotation_seconds"
+	keyFileEnv  = "private_key_file"
+	certFileEnv = "certificate_file"
+	exportEnv   = "export_private_keys"
+	renewalEnv  = "cert_renewal"
+	renewEnv    = "cert_renew_before_seconds"
+	seedEnv     = "random_seed"
+	fixedEnv    = "fixed_time"
+	kidStratEnv = "key_id_strategy"
+	kidEnv      = "key_id"
+	kidPrefEnv  = "key_id_prefix"
+	decoyEnv    = "decoy_keys"
+	ttlEnv      = "token_ttl_seconds"
+	issuerEnv   = "issuer"
+	audEnv      = "audience"
 
 	envPrefix = "JWT_MOCK"
 )
 
+// KeyFileConfig points at PEM files for a signing key.
+type KeyFileConfig struct {
+	// PrivateKeyFile is a PEM-encoded RSA, ECDSA or Ed25519 private key.
+	PrivateKeyFile string `yaml:"private_key_file"`
+
+	// CertificateFile is an optional PEM-encoded certificate chain (leaf certificate first) matching the key.
+	CertificateFile string `yaml:"certificate_file"`
+
+	// Algorithm is the signing algorithm to use with the key - derived from the key if empty.
+	Algorithm string `yaml:"algorithm"`
+
+	// KeyID is the ID of the key - the RFC 7638 thumbprint of the key is used if empty.
+	KeyID string `yaml:"key_id"`
+}
+
 // Config is used to hold application configuration values.
 type Config struct {
 	Port                int    `yaml:"port"`
 	KeyLength           int    `yaml:"key_length"`
 	CertificateLifeDays int    `yaml:"cert_life_days"`
 	LogLevel            string `yaml:"log_level"`
+	SigningAlgorithm    string `yaml:"signing_algorithm"`
+	HMACSecret          string `yaml:"hmac_secret"`
+	PublishHMACKey      bool   `yaml:"publish_hmac_key"`
+
+	// AdditionalKeys are algorithms of keys published alongside the main signing key.
+	AdditionalKeys []string `yaml:"additional_keys"`
+
+	// KeyRetentionSeconds is how long keys stay published after rotation - zero keeps them until retired, or for one
+	// rotation interval with automatic key rotation.
+	KeyRetentionSeconds int `yaml:"key_retention_seconds"`
+
+	// KeyRotationSeconds is the interval for automatic key rotation - zero disables it.
+	KeyRotationSeconds int `yaml:"key_rotation_seconds"`
+
+	// SigningKeys are loaded from files instead of generating a signing key - the first is the current key.
+	SigningKeys []KeyFileConfig `yaml:"signing_keys"`
+
+	// ExportPrivateKeys enables the endpoints that export private signing keys and the HMAC shared secret - never
+	// enable this for shared servers.
+	ExportPrivateKeys bool `yaml:"export_private_keys"`
+
+	// CertificateRenewal is what happens ahead of certificate expiry - "rotate" replaces keys, "renew" issues a new
+	// certificate for the same key and empty does nothing.
+	CertificateRenewal string `yaml:"cert_renewal"`
+
+	// CertificateRenewBeforeSeconds is how long before certificate expiry keys are rotated or renewed - a tenth of
+	// the certificate lifetime if zero.
+	CertificateRenewBeforeSeconds int `yaml:"cert_renew_before_seconds"`
+
+	// RandomSeed makes keys, key IDs and certificates deterministic when non-zero - only use this for tests.
+	RandomSeed int64 `yaml:"random_seed"`
+
+	// FixedTime fixes the clock used for certificates, default claims and claim validation at an RFC 3339 time when a
+	// random seed is set.
+	FixedTime string `yaml:"fixed_time"`
+
+	// KeyIDStrategy is how key IDs of generated keys are chosen - random (default), thumbprint or fixed.
+	KeyIDStrategy string `yaml:"key_id_strategy"`
+
+	// KeyID is the key ID used with the fixed strategy.
+	KeyID string `yaml:"key_id"`
+
+	// KeyIDPrefix is prepended to key IDs of generated keys.
+	KeyIDPrefix string `yaml:"key_id_prefix"`
+
+	// DecoyKeys are entries published in the JWKS ahead of the signing keys which consumers must ignore - enc,
+	// unknown-kty, no-alg or duplicate-kid.
+	DecoyKeys []string `yaml:"decoy_keys"`
+
+	// TokenTTLSeconds is the lifetime of generated JWTs without an "exp" claim - one hour if zero.
+	TokenTTLSeconds int `yaml:"token_ttl_seconds"`
+
+	// Issuer is set as the "iss" claim of generated JWTs without one.
+	Issuer string `yaml:"issuer"`
+
+	// Audience is set as the "aud" claim of generated JWTs without one.
+	Audience []string `yaml:"audience"`
 }
 
 // GetCertificateDuration returns the cert lifetime duration.
@@ -33,9 +127,76 @@ func (c *Config) GetCertificateDuration() time.Duration {
 	return time.Hour * 24 * time.Duration(c.CertificateLifeDays)
 }
 
+// GetCertificateRenewal returns what happens ahead of certificate expiry.
+func (c *Config) GetCertificateRenewal() jwtmock.CertificateRenewal {
+	return jwtmock.CertificateRenewal(c.CertificateRenewal)
+}
+
+// GetCertificateRenewBefore returns how long before certificate expiry keys are rotated or renewed.
+func (c *Config) GetCertificateRenewBefore() time.Duration {
+	if c.CertificateRenewBeforeSeconds == 0 {
+		return c.GetCertificateDuration() / 10
+	}
+
+	return time.Second * time.Duration(c.CertificateRenewBeforeSeconds)
+}
+
+// GetFixedTime returns the fixed clock time - zero if the system clock is used.
+func (c *Config) GetFixedTime() (time.Time, error) {
+	if c.FixedTime == "" {
+		return time.Time{}, nil
+	}
+
+	return time.Parse(time.RFC3339, c.FixedTime)
+}
+
+// GetClaimDefaults returns the defaults for standard claims missing from generated JWTs.
+func (c *Config) GetClaimDefaults() jwtmock.ClaimDefaults {
+	ttl := jwtmock.DefaultTokenTTL
+	if c.TokenTTLSeconds != 0 {
+		ttl = time.Second * time.Duration(c.TokenTTLSeconds)
+	}
+
+	return jwtmock.ClaimDefaults{
+		TTL:      ttl,
+		Issuer:   c.Issuer,
+		Audience: c.Audience,
+	}
+}
+
+// GetDecoyKeys returns the decoy entries published in the JWKS.
+func (c *Config) GetDecoyKeys() []jwtmock.DecoyKey {
+	decoys := make([]jwtmock.DecoyKey, 0, len(c.DecoyKeys))
+	for _, decoy := range c.DecoyKeys {
+		decoys = append(decoys, jwtmock.DecoyKey(decoy))
+	}
+
+	return decoys
+}
+
+// GetKeyRetention returns how long keys stay published after rotation.
+func (c *Config) GetKeyRetention() time.Duration {
+	return time.Second * time.Duration(c.KeyRetentionSeconds)
+}
+
+// GetKeyRotationInterval returns the interval for automatic key rotation.
+func (c *Config) GetKeyRotationInterval() time.Duration {
+	return time.Second * time.Duration(c.KeyRotationSeconds)
+}
+
+// GetSigningAlgorithm returns the algorithm used to sign JWTs - defaults to RS256.
+func (c *Config) GetSigningAlgorithm() jwa.SignatureAlgorithm {
+	if c.SigningAlgorithm == "" {
+		return jwa.RS256
+	}
+
+	return jwa.SignatureAlgorithm(c.SigningAlgorithm)
+}
+
 // String returns a string representation of config.
 func (c Config) String() string {
-	return fmt.Sprintf("port=%d key-length=%d cert-life=%v", c.Port, c.KeyLength, c.GetCertificateDuration())
+	return fmt.Sprintf("port=%d key-length=%d cert-life=%v signing-alg=%v", c.Port, c.KeyLength,
+		c.GetCertificateDuration(), c.GetSigningAlgorithm())
 }
 
 // LoadConfig reads the given YAML file and loads config from it
@@ -66,6 +227,84 @@ func LoadConfig(yamlFileName string) (*Config, error) {
 		cfg.LogLevel = val
 	}
 
+	if val, ok := getEnvVarStr(sigAlgEnv); ok {
+		cfg.SigningAlgorithm = val
+	}
+
+	if val, ok := getEnvVarStr(hmacEnv); ok {
+		cfg.HMACSecret = val
+	}
+
+	if val, ok := getEnvVarBool(pubHMACEnv); ok {
+		cfg.PublishHMACKey = val
+	}
+
+	if val, ok := getEnvVarStrList(addKeysEnv); ok {
+		cfg.AdditionalKeys = val
+	}
+
+	if val, ok := getEnvVarInt(retainEnv); ok {
+		cfg.KeyRetentionSeconds = val
+	}
+
+	if val, ok := getEnvVarInt(rotateEnv); ok {
+		cfg.KeyRotationSeconds = val
+	}
+
+	if val, ok := getEnvVarBool(exportEnv); ok {
+		cfg.ExportPrivateKeys = val
+	}
+
+	if val, ok := getEnvVarStr(renewalEnv); ok {
+		cfg.CertificateRenewal = val
+	}
+
+	if val, ok := getEnvVarInt(renewEnv); ok {
+		cfg.CertificateRenewBeforeSeconds = val
+	}
+
+	if val, ok := getEnvVarInt(seedEnv); ok {
+		cfg.RandomSeed = int64(val)
+	}
+
+	if val, ok := getEnvVarStr(fixedEnv); ok {
+		cfg.FixedTime = val
+	}
+
+	if val, ok := getEnvVarStr(kidStratEnv); ok {
+		cfg.KeyIDStrategy = val
+	}
+
+	if val, ok := getEnvVarStr(kidEnv); ok {
+		cfg.KeyID = val
+	}
+
+	if val, ok := getEnvVarStr(kidPrefEnv); ok {
+		cfg.KeyIDPrefix = val
+	}
+
+	if val, ok := getEnvVarStrList(decoyEnv); ok {
+		cfg.DecoyKeys = val
+	}
+
+	if val, ok := getEnvVarInt(ttlEnv); ok {
+		cfg.TokenTTLSeconds = val
+	}
+
+	if val, ok := getEnvVarStr(issuerEnv); ok {
+		cfg.Issuer = val
+	}
+
+	if val, ok := getEnvVarStrList(audEnv); ok {
+		cfg.Audience = val
+	}
+
+	// a single signing key can be set through environment variables
+	if val, ok := getEnvVarStr(keyFileEnv); ok {
+		certFile, _ := getEnvVarStr(certFileEnv)
+		cfg.SigningKeys = []KeyFileConfig{{PrivateKeyFile: val, CertificateFile: certFile}}
+	}
+
 	return &cfg, nil
 }
 
@@ -83,6 +322,22 @@ func getEnvVarInt(s string) (int, bool) {
 
 	return parseInt, true
 }
+
+func getEnvVarBool(s string) (bool, bool) {
+	varName := fmt.Sprintf("%v_%v", envPrefix, strings.ToUpper(s))
+	varStr := os.Getenv(varName)
+	if varStr == "" {
+		return false, false
+	}
+
+	parseBool, err := strconv.ParseBool(varStr)
+	if err != nil {
+		return false, false
+	}
+
+	return parseBool, true
+}
+
 func getEnvVarStr(s string) (string, bool) {
 	varName := fmt.Sprintf("%v_%v", envPrefix, strings.ToUpper(s))
 	varStr := os.Getenv(varName)
@@ -93,3 +348,19 @@ func getEnvVarStr(s string) (string, bool) {
 
 	return varStr, present
 }
+
+func getEnvVarStrList(s string) ([]string, bool) {
+	varStr, present := getEnvVarStr(s)
+	if !present {
+		return nil, false
+	}
+
+	var list []string
+	for _, item := range strings.Split(varStr, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+
+	return list, true
+}
@@ -7,6 +7,8 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/nayyara-cropsey/jwtmock"
 	"github.com/nayyara-cropsey/jwtmock/internal/handlers"
 	"github.com/nayyara-cropsey/jwtmock/internal/jwks"
 	"github.com/nayyara-cropsey/jwtmock/internal/service"
@@ -28,20 +30,43 @@ func Serve(ctx context.Context, configFile string) error {
 	logger := log.NewLogger(log.WithLevelStr(cfg.LogLevel))
 	logger.Infof("Config: %v", cfg)
 
-	certGenerator := service.NewCertificateGenerator(cfg.GetCertificateDuration())
-	keyGenerator := jwks.NewGenerator(certGenerator, service.NewRSAKeyGenerator(), cfg.KeyLength)
-	keyStore, err := service.NewKeyStore(keyGenerator)
+	source, err := newSource(cfg)
+	if err != nil {
+		return fmt.Errorf("fixed time: %w", err)
+	}
+
+	if source.Deterministic() {
+		logger.Warn("Keys are deterministic - do not use this server outside tests")
+	}
+
+	certGenerator, err := service.NewCertificateGenerator(cfg.GetCertificateDuration(), source)
+	if err != nil {
+		logger.Errorf("Error while initializing root CA: %v", err)
+		return err
+	}
+
+	keyStore, err := newKeyStore(cfg, certGenerator, source)
 	if err != nil {
 		logger.Errorf("Error while initializing key store: %v", err)
 		return err
 	}
 
-	clientRepo := service.NewClientRepo()
-	mainHandler := handlers.NewHandler(keyStore, clientRepo, logger)
+	// rotation and certificate renewal stop when the server is shut down
+	var rotationDone <-chan struct{}
+	if interval := cfg.GetKeyRotationInterval(); interval > 0 {
+		logger.Infof("Rotating keys every %v", interval)
+		rotationDone = keyStore.StartRotation(ctx, interval, logger)
+	}
+
+	renewalDone := keyStore.StartCertificateRenewal(ctx, logger)
+	if renewalDone != nil {
+		logger.Infof("Certificate renewal: %v %v before expiry", cfg.GetCertificateRenewal(),
+			cfg.GetCertificateRenewBefore())
+	}
 
 	s := &http.Server{
 		Addr:    fmt.Sprintf(":%d", cfg.Port),
-		Handler: mainHandler,
+		Handler: newHandler(cfg, keyStore, certGenerator, source, logger),
 
 		// add timeout to avoid long I/O waits
 		ReadTimeout:  time.Minute,
@@ -69,7 +94,107 @@ func Serve(ctx context.Context, configFile string) error {
 		return err
 	}
 
+	if rotationDone != nil {
+		<-rotationDone
+	}
+
+	if renewalDone != nil {
+		<-renewalDone
+	}
+
 	logger.Info("Server shutdown complete")
 
 	return nil
 }
+
+// newHandler creates the HTTP handler with the configured optional routes and claim defaults - JWT IDs and the time
+// of default claims are drawn from the given source.
+func newHandler(cfg *Config, keyStore *service.KeyStore, certGenerator *service.CertificateGenerator,
+	source *service.Source, logger *log.Logger) http.Handler {
+	claimDefaults := cfg.GetClaimDefaults()
+	claimDefaults.Rand = source
+	claimDefaults.Now = source.Now
+
+	handlerOptions := []handlers.HandlerOption{
+		handlers.WithRootCertificate(service.EncodeCertificates(certGenerator.RootCertificate())),
+		handlers.WithClaimDefaults(claimDefaults),
+	}
+
+	if cfg.ExportPrivateKeys {
+		logger.Warn("Private key export is enabled")
+		handlerOptions = append(handlerOptions, handlers.WithPrivateKeyExport(service.ExportSigningKey))
+	}
+
+	clientRepo := service.NewClientRepo()
+
+	return handlers.NewHandler(keyStore, clientRepo, logger, handlerOptions...)
+}
+
+// newKeyStore creates a key store with the configured signing keys - generated keys are certified by the given
+// certificate generator.
+func newKeyStore(cfg *Config, certGenerator *service.CertificateGenerator, source *service.Source) (
+	*service.KeyStore, error) {
+	signingKeyGenerator, err := newKeyGenerator(cfg, source)
+	if err != nil {
+		return nil, fmt.Errorf("key generator: %w", err)
+	}
+
+	keyID, err := service.NewKeyIDFunc(jwtmock.KeyIDStrategy(cfg.KeyIDStrategy), cfg.KeyID, cfg.KeyIDPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	keyGenerator := jwks.NewGenerator(certGenerator, signingKeyGenerator, cfg.KeyLength,
+		jwks.WithSymmetricKeyPublishing(cfg.PublishHMACKey), jwks.WithKeyIDFunc(keyID))
+	options := []service.KeyStoreOption{
+		service.WithKeyRetention(cfg.GetKeyRetention()),
+		service.WithCertificateRenewal(cfg.GetCertificateRenewal(), cfg.GetCertificateRenewBefore()),
+		service.WithSource(source),
+		service.WithDecoyKeys(cfg.GetDecoyKeys()...),
+	}
+	for _, keyFile := range cfg.SigningKeys {
+		signingKey, err := service.LoadSigningKeyFiles(keyFile.PrivateKeyFile, keyFile.CertificateFile,
+			jwa.SignatureAlgorithm(keyFile.Algorithm), keyFile.KeyID)
+		if err != nil {
+			return nil, fmt.Errorf("load %v: %w", keyFile.PrivateKeyFile, err)
+		}
+
+		options = append(options, service.WithSigningKeys(signingKey))
+	}
+
+	keyStore, err := service.NewKeyStore(keyGenerator, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, alg := range cfg.AdditionalKeys {
+		if _, err = keyStore.AddKey(jwa.SignatureAlgorithm(alg)); err != nil {
+			return nil, fmt.Errorf("add %v key: %w", alg, err)
+		}
+	}
+
+	return keyStore, nil
+}
+
+// newKeyGenerator creates a key generator for the configured algorithm - using the configured HMAC secret if any.
+func newKeyGenerator(cfg *Config, source *service.Source) (service.KeyGenerator, error) {
+	if cfg.HMACSecret != "" {
+		return service.NewHMACKeyGenerator(cfg.GetSigningAlgorithm(), []byte(cfg.HMACSecret), source)
+	}
+
+	return service.NewKeyGenerator(cfg.GetSigningAlgorithm(), source)
+}
+
+// newSource creates the source of randomness - which is deterministic if a random seed is configured.
+func newSource(cfg *Config) (*service.Source, error) {
+	if cfg.RandomSeed == 0 {
+		return service.NewSource(), nil
+	}
+
+	fixedTime, err := cfg.GetFixedTime()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.NewSeededSource(cfg.RandomSeed, fixedTime), nil
+}
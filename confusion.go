@@ -0,0 +1,112 @@
+package jwtmock
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+)
+
+var (
+	// ErrUnsupportedConfusion means the algorithm or public key encoding is not supported for algorithm confusion.
+	ErrUnsupportedConfusion = errors.New("unsupported algorithm confusion")
+
+	// ErrNoPublicKey means the signing key is symmetric, so there is no public key.
+	ErrNoPublicKey = errors.New("signing key has no public key")
+)
+
+// PublicKeyEncoding describes how a public key is encoded when used as an HMAC secret.
+type PublicKeyEncoding string
+
+const (
+	// PublicKeyPEM is the PEM-encoded PKIX public key - the encoding most libraries load keys from.
+	PublicKeyPEM PublicKeyEncoding = "pem"
+
+	// PublicKeyDER is the DER-encoded PKIX public key.
+	PublicKeyDER PublicKeyEncoding = "der"
+
+	// PublicKeyJWK is the JSON encoding of the public JWK.
+	PublicKeyJWK PublicKeyEncoding = "jwk"
+)
+
+// PublicKeyEncodings are all public key encodings used for algorithm confusion.
+var PublicKeyEncodings = []PublicKeyEncoding{PublicKeyPEM, PublicKeyDER, PublicKeyJWK}
+
+// CreateConfusionJWT generates an algorithm confusion JWT from the claims - it is signed with the given HMAC algorithm
+// using the encoded public key of the signing key as the secret, along with the key ID of the signing key. Consumers
+// that accept it verify HMAC JWTs with public keys. Claims are not validated and only the options setting headers
+// ("jku", "x5u", "x5c" and custom headers) are used.
+func (c Claims) CreateConfusionJWT(signingKey *SigningKey, alg jwa.SignatureAlgorithm,
+	encoding PublicKeyEncoding, options ...TokenOption) (string, error) {
+	switch alg {
+	case jwa.HS256, jwa.HS384, jwa.HS512:
+	default:
+		return "", fmt.Errorf("%w: %v is not an HMAC algorithm", ErrUnsupportedConfusion, alg)
+	}
+
+	if signingKey.IsSymmetric() {
+		return "", ErrNoPublicKey
+	}
+
+	secret, err := EncodePublicKey(signingKey.PublicKey, encoding)
+	if err != nil {
+		return "", err
+	}
+
+	// the header refers to the signing key, so "x5c" is its certificate chain
+	return c.CreateJWT(&SigningKey{
+		ID:           signingKey.ID,
+		Key:          secret,
+		Algorithm:    alg,
+		Certificates: signingKey.Certificates,
+	}, confusionHeaderOptions(NewTokenOptions(options...))...)
+}
+
+// confusionHeaderOptions returns the token options of an algorithm confusion JWT - its claims are unchecked and only
+// the options setting headers are kept.
+func confusionHeaderOptions(opts *TokenOptions) []TokenOption {
+	options := []TokenOption{WithUncheckedClaims(), WithHeaders(opts.Headers)}
+	if opts.KeySetURL != "" {
+		options = append(options, WithKeySetURL(opts.KeySetURL))
+	}
+
+	if opts.CertificateURL != "" {
+		options = append(options, WithCertificateURL(opts.CertificateURL))
+	}
+
+	if opts.CertificateChain {
+		options = append(options, WithCertificateChain())
+	}
+
+	return options
+}
+
+// EncodePublicKey returns the public key in the given encoding.
+func EncodePublicKey(publicKey interface{}, encoding PublicKeyEncoding) ([]byte, error) {
+	switch encoding {
+	case PublicKeyPEM, PublicKeyDER:
+		derBytes, err := x509.MarshalPKIXPublicKey(publicKey)
+		if err != nil {
+			return nil, fmt.Errorf("marshal public key: %w", err)
+		}
+
+		if encoding == PublicKeyDER {
+			return derBytes, nil
+		}
+
+		return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: derBytes}), nil
+	case PublicKeyJWK:
+		key, err := jwk.New(publicKey)
+		if err != nil {
+			return nil, fmt.Errorf("jwk: %w", err)
+		}
+
+		return json.Marshal(key)
+	default:
+		return nil, fmt.Errorf("%w: %v encoding", ErrUnsupportedConfusion, encoding)
+	}
+}
@@ -3,8 +3,8 @@ module github.com/nayyara-cropsey/jwtmock
 go 1.16
 
 require (
-	github.com/lestrrat-go/jwx v1.0.3
+	github.com/lestrrat-go/jwx v1.0.8
 	github.com/mitchellh/mapstructure v1.4.1
 	github.com/stretchr/testify v1.7.0
-	gopkg.in/yaml.v2 v2.4.0 // indirect
+	gopkg.in/yaml.v2 v2.4.0
 )
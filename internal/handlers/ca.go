@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/nayyara-cropsey/jwtmock/log"
+)
+
+// CADefaultPath is the default path for the root CA certificate handler.
+const CADefaultPath = "/jwtmock/ca.pem"
+
+// CAHandler provides handlers for retrieving the root CA certificate that signs the certificates in "x5c".
+type CAHandler struct {
+	rootPEM []byte
+	logger  *log.Logger
+}
+
+// NewCAHandler is the preferred way to create a CAHandler instance.
+func NewCAHandler(rootPEM []byte, logger *log.Logger) *CAHandler {
+	return &CAHandler{
+		rootPEM: rootPEM,
+		logger:  logger,
+	}
+}
+
+// RegisterDefaultPaths registers the default paths for root CA operations.
+func (h *CAHandler) RegisterDefaultPaths(api *http.ServeMux) {
+	api.HandleFunc(CADefaultPath, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			h.Get(w, r)
+		default:
+			notFoundResponse(w)
+		}
+	})
+}
+
+// Get returns the PEM-encoded root CA certificate for use as a trust anchor.
+func (h *CAHandler) Get(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/x-pem-file")
+
+	if _, err := w.Write(h.rootPEM); err != nil {
+		h.logger.Errorf("Failed write PEM response: %v", err)
+	}
+}
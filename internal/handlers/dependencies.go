@@ -1,6 +1,9 @@
 package handlers
 
 import (
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/lestrrat-go/jwx/jwk"
 	"github.com/nayyara-cropsey/jwtmock"
 )
@@ -9,9 +12,22 @@ type keyStore interface {
 	GenerateNew() error
 	GetJWKS() *jwk.Set
 	GetSigningKey() *jwtmock.SigningKey
+	FindSigningKey(kid string, alg jwa.SignatureAlgorithm) (*jwtmock.SigningKey, error)
+	AddKey(alg jwa.SignatureAlgorithm) (*jwtmock.SigningKey, error)
+	Rotate() (*jwtmock.SigningKey, error)
+	RotateWithRetention(retention time.Duration) (*jwtmock.SigningKey, error)
+	Retire(kid string) error
+	Deactivate(kid string) error
+	ListKeys() []jwtmock.KeyInfo
+	StageKey(alg jwa.SignatureAlgorithm, publishAfter time.Duration, current bool) (*jwtmock.SigningKey, error)
+	Publish(kid string) error
+	PublishedKeys() []*jwtmock.SigningKey
+	FindKey(kid string) (jwk.Key, *jwtmock.SigningKey, error)
 }
 
 type clientRepo interface {
 	Register(jwtmock.ClientRegistration) error
 	GenerateToken(jwtmock.ClientTokenRequest, *jwtmock.SigningKey) (*jwtmock.ClientTokenResponse, error)
 }
+
+type keyExporter func(*jwtmock.SigningKey) (*jwtmock.PrivateKeyExport, error)
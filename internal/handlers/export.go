@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/nayyara-cropsey/jwtmock"
+	"github.com/nayyara-cropsey/jwtmock/log"
+)
+
+// ExportDefaultPath is the default path for exporting the private signing key.
+const ExportDefaultPath = "/jwtmock/signing-key"
+
+// ExportHandler provides handlers for exporting private signing material - it must only be registered when
+// explicitly enabled.
+type ExportHandler struct {
+	keyStore keyStore
+	exporter keyExporter
+	logger   *log.Logger
+}
+
+// NewExportHandler is the preferred way to create an ExportHandler instance.
+func NewExportHandler(keyStore keyStore, exporter keyExporter, logger *log.Logger) *ExportHandler {
+	return &ExportHandler{
+		keyStore: keyStore,
+		exporter: exporter,
+		logger:   logger,
+	}
+}
+
+// RegisterDefaultPaths registers the default paths for private key export.
+func (h *ExportHandler) RegisterDefaultPaths(api *http.ServeMux) {
+	api.HandleFunc(ExportDefaultPath, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			h.Get(w, r)
+		default:
+			notFoundResponse(w)
+		}
+	})
+}
+
+// Get returns the current signing key (or the key selected by ID) as PEM and as a private JWK.
+func (h *ExportHandler) Get(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	signingKey, err := h.keyStore.FindSigningKey(r.URL.Query().Get(keyIDParam), "")
+	if err != nil {
+		h.logger.Errorf("Failed to find signing key: %v", err)
+
+		w.WriteHeader(http.StatusNotFound)
+
+		if err = jsonMarshal(w, errorResponse{
+			Message: "Failed to find signing key",
+			Error:   err.Error(),
+		}); err != nil {
+			h.logger.Errorf("Failed write JSON response: %v", err)
+		}
+
+		return
+	}
+
+	var export *jwtmock.PrivateKeyExport
+	if export, err = h.exporter(signingKey); err != nil {
+		h.logger.Errorf("Failed to export signing key: %v", err)
+
+		w.WriteHeader(http.StatusInternalServerError)
+
+		if err = jsonMarshal(w, errorResponse{
+			Message: "Failed to export signing key",
+			Error:   err.Error(),
+		}); err != nil {
+			h.logger.Errorf("Failed write JSON response: %v", err)
+		}
+
+		return
+	}
+
+	if err := jsonMarshal(w, export); err != nil {
+		h.logger.Errorf("Failed write JSON response: %v", err)
+	}
+}
@@ -2,12 +2,24 @@ package handlers
 
 import (
 	"net/http"
+	"strings"
 
+	"github.com/lestrrat-go/jwx/jwk"
 	"github.com/nayyara-cropsey/jwtmock/log"
 )
 
-// JWKSDefaultPath is the default path for JWKS handlers.
-const JWKSDefaultPath = "/.well-known/jwks.json"
+const (
+	// JWKSDefaultPath is the default path for JWKS handlers.
+	JWKSDefaultPath = "/.well-known/jwks.json"
+
+	// KeyJWKSDefaultPath is the default path for handlers serving a JWKS with a single key - used as "jku".
+	KeyJWKSDefaultPath = "/jwtmock/jwks"
+)
+
+// jwksResponse is the JSON representation of a JWK set as defined in RFC 7517 section 5.
+type jwksResponse struct {
+	Keys []jwk.Key `json:"keys"`
+}
 
 // JWKSHandler provides handlers for JWKS operations and stores state of the current JWKS.
 type JWKSHandler struct {
@@ -35,13 +47,39 @@ func (h *JWKSHandler) RegisterDefaultPaths(api *http.ServeMux) {
 			notFoundResponse(w)
 		}
 	})
+
+	api.HandleFunc(KeyJWKSDefaultPath+"/", func(w http.ResponseWriter, r *http.Request) {
+		kid := strings.TrimPrefix(r.URL.Path, KeyJWKSDefaultPath+"/")
+
+		switch {
+		case kid != "" && !strings.Contains(kid, "/") && r.Method == http.MethodGet:
+			h.GetKey(w, r, kid)
+		default:
+			notFoundResponse(w)
+		}
+	})
+}
+
+// GetKey returns a JSON web key set containing only the key with the given ID - staged keys are included.
+func (h *JWKSHandler) GetKey(w http.ResponseWriter, _ *http.Request, kid string) {
+	key, _, err := h.keyStore.FindKey(kid)
+	if err != nil || key == nil {
+		notFoundResponse(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := jsonMarshal(w, jwksResponse{Keys: []jwk.Key{key}}); err != nil {
+		h.logger.Errorf("Failed write JSON response: %v", err)
+	}
 }
 
 // Get returns a JSON web key set for the authorization server.
 func (h *JWKSHandler) Get(w http.ResponseWriter, _ *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	if err := jsonMarshal(w, h.keyStore.GetJWKS()); err != nil {
+	if err := jsonMarshal(w, jwksResponse{Keys: h.keyStore.GetJWKS().Keys}); err != nil {
 		h.logger.Errorf("Failed write JSON response: %v", err)
 		return
 	}
@@ -1,8 +1,13 @@
 package handlers
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
 
+	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/nayyara-cropsey/jwtmock"
 	"github.com/nayyara-cropsey/jwtmock/log"
 )
@@ -14,17 +19,38 @@ type jwtResponse struct {
 // JWTDefaultPath is the default path for JWT handlers.
 const JWTDefaultPath = "/jwtmock/generate-jwt"
 
+const (
+	keyIDParam     = "kid" // query parameter used to select the signing key by ID
+	algorithmParam = "alg" // query parameter used to select the signing key by algorithm
+
+	keySetURLParam = "jku" // query parameter used to set the "jku" header
+	certURLParam   = "x5u" // query parameter used to set the "x5u" header
+	certChainParam = "x5c" // query parameter used to include the certificate chain as the "x5c" header
+
+	uncheckedParam = "unchecked" // query parameter used to sign claims without validating them
+	malformedParam = "malformed" // query parameter used to generate a malformed JWT variant
+
+	confusionParam    = "confusion"     // query parameter used to generate an algorithm confusion JWT
+	confusionAlgParam = "confusion_alg" // query parameter used to set the HMAC algorithm of algorithm confusion JWTs
+
+	claimsMember  = "claims"  // JWT request body member holding the claims
+	headersMember = "headers" // JWT request body member holding the custom headers
+)
+
 // JWTHandler provides handlers for working with JWTs
 type JWTHandler struct {
-	keyStore keyStore
-	logger   *log.Logger
+	keyStore      keyStore
+	claimDefaults jwtmock.ClaimDefaults
+	logger        *log.Logger
 }
 
-// NewJWTHandler is the preferred way to create a JWTHandler instance.
-func NewJWTHandler(keyStore keyStore, logger *log.Logger) *JWTHandler {
+// NewJWTHandler is the preferred way to create a JWTHandler instance - the claim defaults fill missing standard
+// claims.
+func NewJWTHandler(keyStore keyStore, claimDefaults jwtmock.ClaimDefaults, logger *log.Logger) *JWTHandler {
 	return &JWTHandler{
-		keyStore: keyStore,
-		logger:   logger,
+		keyStore:      keyStore,
+		claimDefaults: claimDefaults,
+		logger:        logger,
 	}
 }
 
@@ -40,12 +66,14 @@ func (h *JWTHandler) RegisterDefaultPaths(api *http.ServeMux) {
 	})
 }
 
-// Post creates a signed JWT with the provided claims.
+// Post creates a signed JWT with the provided claims - missing standard claims are filled from the claim defaults and
+// claims are validated unless the unchecked query parameter is set. The body is either the claims or a JWT request
+// with only a "claims" object and custom headers.
 func (h *JWTHandler) Post(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	var claims jwtmock.Claims
-	if err := jsonUnmarshal(r, &claims); err != nil {
+	req, err := readJWTRequest(r)
+	if err != nil {
 		h.logger.Errorf("Failed to read claims: %v", err)
 
 		w.WriteHeader(http.StatusBadRequest)
@@ -60,8 +88,63 @@ func (h *JWTHandler) Post(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	signingKey := h.keyStore.GetSigningKey()
-	token, err := claims.CreateJWT(signingKey)
+	claims, err := req.Claims.WithDefaults(h.claimDefaults)
+	if err != nil {
+		h.logger.Errorf("Failed to set default claims: %v", err)
+
+		w.WriteHeader(http.StatusInternalServerError)
+
+		if err = jsonMarshal(w, errorResponse{
+			Message: "Failed to set default claims",
+			Error:   err.Error(),
+		}); err != nil {
+			h.logger.Errorf("Failed write JSON response: %v", err)
+		}
+
+		return
+	}
+
+	query := r.URL.Query()
+	signingKey, err := h.keyStore.FindSigningKey(query.Get(keyIDParam), jwa.SignatureAlgorithm(query.Get(algorithmParam)))
+	if err != nil {
+		h.logger.Errorf("Failed to find signing key: %v", err)
+
+		w.WriteHeader(http.StatusBadRequest)
+
+		if err = jsonMarshal(w, errorResponse{
+			Message: "Failed to find signing key",
+			Error:   err.Error(),
+		}); err != nil {
+			h.logger.Errorf("Failed write JSON response: %v", err)
+		}
+
+		return
+	}
+
+	options, err := tokenOptions(query)
+	if err != nil {
+		h.logger.Errorf("Failed to read token options: %v", err)
+
+		w.WriteHeader(http.StatusBadRequest)
+
+		if err = jsonMarshal(w, errorResponse{
+			Message: "Failed to read token options",
+			Error:   err.Error(),
+		}); err != nil {
+			h.logger.Errorf("Failed write JSON response: %v", err)
+		}
+
+		return
+	}
+
+	if req.Headers != nil {
+		options = append(options, jwtmock.WithHeaders(req.Headers))
+	}
+
+	// claims are validated against the clock they are defaulted from
+	options = append(options, jwtmock.WithClock(h.claimDefaults.Now))
+
+	token, err := claims.CreateJWT(signingKey, options...)
 	if err != nil {
 		h.logger.Errorf("Failed to generate JWT: %v", err)
 
@@ -85,3 +168,78 @@ func (h *JWTHandler) Post(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+// readJWTRequest reads the request body - it is a JWT request if it has a "claims" or "headers" member, otherwise it
+// is the claims. A JWT request with any other member is rejected rather than dropping what may be claims.
+func readJWTRequest(r *http.Request) (*jwtmock.JWTRequest, error) {
+	var body map[string]json.RawMessage
+	if err := jsonUnmarshal(r, &body); err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &jwtmock.JWTRequest{}
+
+	_, hasClaims := body[claimsMember]
+	_, hasHeaders := body[headersMember]
+
+	if !hasClaims && !hasHeaders {
+		return req, json.Unmarshal(data, &req.Claims)
+	}
+
+	for name := range body {
+		if name != claimsMember && name != headersMember {
+			return nil, fmt.Errorf("JWT request member %q must be in the %q object", name, claimsMember)
+		}
+	}
+
+	return req, json.Unmarshal(data, req)
+}
+
+// tokenOptions returns the token options set by query parameters - other than those selecting the signing key.
+func tokenOptions(query url.Values) ([]jwtmock.TokenOption, error) {
+	var options []jwtmock.TokenOption
+	if jku := query.Get(keySetURLParam); jku != "" {
+		options = append(options, jwtmock.WithKeySetURL(jku))
+	}
+
+	if x5u := query.Get(certURLParam); x5u != "" {
+		options = append(options, jwtmock.WithCertificateURL(x5u))
+	}
+
+	if variant := query.Get(malformedParam); variant != "" {
+		options = append(options, jwtmock.WithMalformedToken(jwtmock.MalformedToken(variant)))
+	}
+
+	if encoding := query.Get(confusionParam); encoding != "" {
+		options = append(options, jwtmock.WithAlgorithmConfusion(jwa.SignatureAlgorithm(query.Get(confusionAlgParam)),
+			jwtmock.PublicKeyEncoding(encoding)))
+	}
+
+	flags := map[string]jwtmock.TokenOption{
+		certChainParam: jwtmock.WithCertificateChain(),
+		uncheckedParam: jwtmock.WithUncheckedClaims(),
+	}
+
+	for param, option := range flags {
+		val := query.Get(param)
+		if val == "" {
+			continue
+		}
+
+		set, err := strconv.ParseBool(val)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", param, err)
+		}
+
+		if set {
+			options = append(options, option)
+		}
+	}
+
+	return options, nil
+}
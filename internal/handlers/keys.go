@@ -0,0 +1,339 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/nayyara-cropsey/jwtmock"
+	"github.com/nayyara-cropsey/jwtmock/log"
+)
+
+// KeysDefaultPath is the default path for signing key handlers.
+const KeysDefaultPath = "/jwtmock/keys"
+
+const (
+	// rotatePath is the sub-path of KeysDefaultPath used to rotate the current signing key.
+	rotatePath = "rotate"
+
+	// stagePath is the sub-path of KeysDefaultPath used to add a key that is not published yet.
+	stagePath = "stage"
+
+	// signingPath is the sub-path of a key used to stop it from signing JWTs.
+	signingPath = "signing"
+
+	// publishPath is the sub-path of a staged key used to publish it.
+	publishPath = "publish"
+)
+
+// keysResponse lists signing keys along with their status.
+type keysResponse struct {
+	Keys []jwtmock.KeyInfo `json:"keys"`
+}
+
+// KeysHandler provides handlers for managing the signing keys published in the JWKS.
+type KeysHandler struct {
+	keyStore keyStore
+	logger   *log.Logger
+}
+
+// NewKeysHandler is the preferred way to create a KeysHandler instance.
+func NewKeysHandler(keyStore keyStore, logger *log.Logger) *KeysHandler {
+	return &KeysHandler{
+		keyStore: keyStore,
+		logger:   logger,
+	}
+}
+
+// RegisterDefaultPaths registers the default paths for signing key operations.
+func (h *KeysHandler) RegisterDefaultPaths(api *http.ServeMux) {
+	api.HandleFunc(KeysDefaultPath, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			h.Get(w, r)
+		case http.MethodPost:
+			h.Post(w, r)
+		default:
+			notFoundResponse(w)
+		}
+	})
+
+	api.HandleFunc(KeysDefaultPath+"/", func(w http.ResponseWriter, r *http.Request) {
+		subPath := strings.TrimPrefix(r.URL.Path, KeysDefaultPath+"/")
+		kid, keyPath := subPath, ""
+		if i := strings.Index(subPath, "/"); i >= 0 {
+			kid, keyPath = subPath[:i], subPath[i+1:]
+		}
+
+		switch {
+		case subPath == rotatePath && r.Method == http.MethodPost:
+			h.Rotate(w, r)
+		case subPath == stagePath && r.Method == http.MethodPost:
+			h.Stage(w, r)
+		case kid != "" && keyPath == "" && r.Method == http.MethodDelete:
+			h.Delete(w, r, kid)
+		case kid != "" && keyPath == signingPath && r.Method == http.MethodDelete:
+			h.DeleteSigning(w, r, kid)
+		case kid != "" && keyPath == publishPath && r.Method == http.MethodPost:
+			h.Publish(w, r, kid)
+		default:
+			notFoundResponse(w)
+		}
+	})
+}
+
+// Get lists all signing keys along with their status.
+func (h *KeysHandler) Get(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := jsonMarshal(w, keysResponse{Keys: h.keyStore.ListKeys()}); err != nil {
+		h.logger.Errorf("Failed write JSON response: %v", err)
+	}
+}
+
+// Post adds a new signing key which is published alongside existing keys - an empty body uses the default algorithm.
+func (h *KeysHandler) Post(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req jwtmock.KeyRequest
+	if err := jsonUnmarshal(r, &req); err != nil && !errors.Is(err, io.EOF) {
+		h.logger.Errorf("Failed to read key request: %v", err)
+
+		w.WriteHeader(http.StatusBadRequest)
+
+		if err = jsonMarshal(w, errorResponse{
+			Message: "Failed to read key request",
+			Error:   err.Error(),
+		}); err != nil {
+			h.logger.Errorf("Failed write JSON response: %v", err)
+		}
+
+		return
+	}
+
+	signingKey, err := h.keyStore.AddKey(jwa.SignatureAlgorithm(req.Algorithm))
+	if err != nil {
+		h.logger.Errorf("Failed to add key: %v", err)
+
+		w.WriteHeader(http.StatusBadRequest)
+
+		if err = jsonMarshal(w, errorResponse{
+			Message: "Failed to add key",
+			Error:   err.Error(),
+		}); err != nil {
+			h.logger.Errorf("Failed write JSON response: %v", err)
+		}
+
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+
+	if err := jsonMarshal(w, jwtmock.KeyInfo{
+		ID:        signingKey.ID,
+		Algorithm: signingKey.Algorithm.String(),
+		Status:    jwtmock.KeyStatusActive,
+	}); err != nil {
+		h.logger.Errorf("Failed write JSON response: %v", err)
+	}
+}
+
+// Rotate replaces the current signing key with a new one - the previous key stays published for the requested
+// retention (or the server default if none is given).
+func (h *KeysHandler) Rotate(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	req, err := readRotateRequest(r)
+	if err != nil {
+		h.logger.Errorf("Failed to read rotate request: %v", err)
+
+		w.WriteHeader(http.StatusBadRequest)
+
+		if err = jsonMarshal(w, errorResponse{
+			Message: "Failed to read rotate request",
+			Error:   err.Error(),
+		}); err != nil {
+			h.logger.Errorf("Failed write JSON response: %v", err)
+		}
+
+		return
+	}
+
+	var signingKey *jwtmock.SigningKey
+	if req.RetentionSeconds != nil {
+		signingKey, err = h.keyStore.RotateWithRetention(time.Duration(*req.RetentionSeconds) * time.Second)
+	} else {
+		signingKey, err = h.keyStore.Rotate()
+	}
+
+	if err != nil {
+		h.logger.Errorf("Failed to rotate key: %v", err)
+
+		w.WriteHeader(http.StatusInternalServerError)
+
+		if err = jsonMarshal(w, errorResponse{
+			Message: "Failed to rotate key",
+			Error:   err.Error(),
+		}); err != nil {
+			h.logger.Errorf("Failed write JSON response: %v", err)
+		}
+
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+
+	if err := jsonMarshal(w, jwtmock.KeyInfo{
+		ID:        signingKey.ID,
+		Algorithm: signingKey.Algorithm.String(),
+		Status:    jwtmock.KeyStatusActive,
+		Current:   true,
+	}); err != nil {
+		h.logger.Errorf("Failed write JSON response: %v", err)
+	}
+}
+
+// Stage adds a new signing key which signs JWTs but is withheld from the JWKS until it is published - after the
+// requested delay or explicitly.
+func (h *KeysHandler) Stage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	req, err := readStageRequest(r)
+	if err != nil {
+		h.logger.Errorf("Failed to read stage request: %v", err)
+
+		w.WriteHeader(http.StatusBadRequest)
+
+		if err = jsonMarshal(w, errorResponse{
+			Message: "Failed to read stage request",
+			Error:   err.Error(),
+		}); err != nil {
+			h.logger.Errorf("Failed write JSON response: %v", err)
+		}
+
+		return
+	}
+
+	signingKey, err := h.keyStore.StageKey(jwa.SignatureAlgorithm(req.Algorithm),
+		time.Duration(req.PublishAfterSeconds)*time.Second, req.Current)
+	if err != nil {
+		h.logger.Errorf("Failed to stage key: %v", err)
+
+		w.WriteHeader(http.StatusBadRequest)
+
+		if err = jsonMarshal(w, errorResponse{
+			Message: "Failed to stage key",
+			Error:   err.Error(),
+		}); err != nil {
+			h.logger.Errorf("Failed write JSON response: %v", err)
+		}
+
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+
+	if err := jsonMarshal(w, jwtmock.KeyInfo{
+		ID:        signingKey.ID,
+		Algorithm: signingKey.Algorithm.String(),
+		Status:    jwtmock.KeyStatusStaged,
+		Current:   req.Current,
+	}); err != nil {
+		h.logger.Errorf("Failed write JSON response: %v", err)
+	}
+}
+
+// Publish publishes the staged key with the given ID - responding 400 if it is not staged.
+func (h *KeysHandler) Publish(w http.ResponseWriter, _ *http.Request, kid string) {
+	if err := h.keyStore.Publish(kid); err != nil {
+		h.logger.Errorf("Failed to publish key: %v", err)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+
+		if err = jsonMarshal(w, errorResponse{
+			Message: "Failed to publish key",
+			Error:   err.Error(),
+		}); err != nil {
+			h.logger.Errorf("Failed write JSON response: %v", err)
+		}
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Delete retires the key with the given ID so that it no longer signs JWTs and is removed from the JWKS.
+func (h *KeysHandler) Delete(w http.ResponseWriter, _ *http.Request, kid string) {
+	if err := h.keyStore.Retire(kid); err != nil {
+		h.logger.Errorf("Failed to retire key: %v", err)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+
+		if err = jsonMarshal(w, errorResponse{
+			Message: "Failed to retire key",
+			Error:   err.Error(),
+		}); err != nil {
+			h.logger.Errorf("Failed write JSON response: %v", err)
+		}
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteSigning deactivates the key with the given ID - responding 400 if it is staged, current or unknown.
+func (h *KeysHandler) DeleteSigning(w http.ResponseWriter, _ *http.Request, kid string) {
+	if err := h.keyStore.Deactivate(kid); err != nil {
+		h.logger.Errorf("Failed to deactivate key: %v", err)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+
+		if err = jsonMarshal(w, errorResponse{
+			Message: "Failed to deactivate key",
+			Error:   err.Error(),
+		}); err != nil {
+			h.logger.Errorf("Failed write JSON response: %v", err)
+		}
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// readRotateRequest reads the optional rotate request body - a negative retention is rejected.
+func readRotateRequest(r *http.Request) (jwtmock.RotateRequest, error) {
+	var req jwtmock.RotateRequest
+	if err := jsonUnmarshal(r, &req); err != nil && !errors.Is(err, io.EOF) {
+		return req, err
+	}
+
+	if req.RetentionSeconds != nil && *req.RetentionSeconds < 0 {
+		return req, fmt.Errorf("retention_seconds must not be negative: %v", *req.RetentionSeconds)
+	}
+
+	return req, nil
+}
+
+// readStageRequest reads the optional stage request body - a negative publish delay is rejected.
+func readStageRequest(r *http.Request) (jwtmock.StageRequest, error) {
+	var req jwtmock.StageRequest
+	if err := jsonUnmarshal(r, &req); err != nil && !errors.Is(err, io.EOF) {
+		return req, err
+	}
+
+	if req.PublishAfterSeconds < 0 {
+		return req, fmt.Errorf("publish_after_seconds must not be negative: %v", req.PublishAfterSeconds)
+	}
+
+	return req, nil
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/nayyara-cropsey/jwtmock"
 	"github.com/nayyara-cropsey/jwtmock/log"
 )
 
@@ -34,19 +35,77 @@ func (r *requestLog) String() string {
 		r.took.Milliseconds(), r.status, http.StatusText(r.status))
 }
 
+// handlerConfig holds settings for optional routes and generated JWTs.
+type handlerConfig struct {
+	keyExporter   keyExporter
+	rootPEM       []byte
+	claimDefaults jwtmock.ClaimDefaults
+}
+
+// HandlerOption allows enabling optional routes on the handler.
+type HandlerOption func(*handlerConfig)
+
+// WithPrivateKeyExport option is used to enable the endpoints that export private signing keys using the
+// given exporter and the HMAC shared secret. These endpoints are never enabled by default.
+func WithPrivateKeyExport(exporter keyExporter) HandlerOption {
+	return func(c *handlerConfig) {
+		c.keyExporter = exporter
+	}
+}
+
+// WithRootCertificate option is used to enable the endpoint that returns the given PEM-encoded root CA certificate.
+func WithRootCertificate(rootPEM []byte) HandlerOption {
+	return func(c *handlerConfig) {
+		c.rootPEM = rootPEM
+	}
+}
+
+// WithClaimDefaults option is used to set the defaults for standard claims missing from generated JWTs - by default
+// JWTs expire after jwtmock.DefaultTokenTTL.
+func WithClaimDefaults(defaults jwtmock.ClaimDefaults) HandlerOption {
+	return func(c *handlerConfig) {
+		c.claimDefaults = defaults
+	}
+}
+
 // NewHandler the fully-wired HTTP handler with all routes registered.
-func NewHandler(keyStore keyStore, clientRepo clientRepo, logger *log.Logger) http.Handler {
+func NewHandler(keyStore keyStore, clientRepo clientRepo, logger *log.Logger, options ...HandlerOption) http.Handler {
+	cfg := &handlerConfig{claimDefaults: jwtmock.ClaimDefaults{TTL: jwtmock.DefaultTokenTTL}}
+	for _, option := range options {
+		option(cfg)
+	}
+
 	mux := http.NewServeMux()
 
 	jwksHandler := NewJWKSHandler(keyStore, logger)
 	jwksHandler.RegisterDefaultPaths(mux)
 
-	jwtHandler := NewJWTHandler(keyStore, logger)
+	jwtHandler := NewJWTHandler(keyStore, cfg.claimDefaults, logger)
 	jwtHandler.RegisterDefaultPaths(mux)
 
 	clientsHandler := NewClientsHandler(keyStore, clientRepo, logger)
 	clientsHandler.RegisterDefaultPaths(mux)
 
+	keysHandler := NewKeysHandler(keyStore, logger)
+	keysHandler.RegisterDefaultPaths(mux)
+
+	pemHandler := NewPEMHandler(keyStore, logger)
+	pemHandler.RegisterDefaultPaths(mux)
+
+	if len(cfg.rootPEM) > 0 {
+		caHandler := NewCAHandler(cfg.rootPEM, logger)
+		caHandler.RegisterDefaultPaths(mux)
+	}
+
+	// the shared secret is as sensitive as a private key so it is only served when key export is enabled
+	if cfg.keyExporter != nil {
+		exportHandler := NewExportHandler(keyStore, cfg.keyExporter, logger)
+		exportHandler.RegisterDefaultPaths(mux)
+
+		secretHandler := NewSecretHandler(keyStore, logger)
+		secretHandler.RegisterDefaultPaths(mux)
+	}
+
 	// wrap mux with a handler that logs requests
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		l := &requestLog{
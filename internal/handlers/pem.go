@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/nayyara-cropsey/jwtmock"
+	"github.com/nayyara-cropsey/jwtmock/internal/service"
+	"github.com/nayyara-cropsey/jwtmock/log"
+)
+
+const (
+	// X509DefaultPath is the default path for the handler mapping key IDs to PEM-encoded certificates.
+	X509DefaultPath = "/jwtmock/x509"
+
+	// PEMDefaultPath is the default path for PEM-encoded public key handlers.
+	PEMDefaultPath = "/jwtmock/pem"
+)
+
+// PEMHandler provides handlers for publishing keys as PEM - for consumers that don't read JWKS.
+type PEMHandler struct {
+	keyStore keyStore
+	logger   *log.Logger
+}
+
+// NewPEMHandler is the preferred way to create a PEMHandler instance.
+func NewPEMHandler(keyStore keyStore, logger *log.Logger) *PEMHandler {
+	return &PEMHandler{
+		keyStore: keyStore,
+		logger:   logger,
+	}
+}
+
+// RegisterDefaultPaths registers the default paths for PEM operations.
+func (h *PEMHandler) RegisterDefaultPaths(api *http.ServeMux) {
+	api.HandleFunc(X509DefaultPath, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			h.GetCertificates(w, r)
+		default:
+			notFoundResponse(w)
+		}
+	})
+
+	api.HandleFunc(X509DefaultPath+"/", func(w http.ResponseWriter, r *http.Request) {
+		kid := strings.TrimPrefix(r.URL.Path, X509DefaultPath+"/")
+
+		switch {
+		case kid != "" && !strings.Contains(kid, "/") && r.Method == http.MethodGet:
+			h.GetCertificateChain(w, r, kid)
+		default:
+			notFoundResponse(w)
+		}
+	})
+
+	api.HandleFunc(PEMDefaultPath, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			h.GetPublicKey(w, r, h.keyStore.GetSigningKey().ID)
+		default:
+			notFoundResponse(w)
+		}
+	})
+
+	api.HandleFunc(PEMDefaultPath+"/", func(w http.ResponseWriter, r *http.Request) {
+		kid := strings.TrimPrefix(r.URL.Path, PEMDefaultPath+"/")
+
+		switch {
+		case kid != "" && !strings.Contains(kid, "/") && r.Method == http.MethodGet:
+			h.GetPublicKey(w, r, kid)
+		default:
+			notFoundResponse(w)
+		}
+	})
+}
+
+// GetCertificates returns a JSON object mapping the ID of each published key to its PEM-encoded leaf certificate -
+// the format used by Google and Firebase.
+func (h *PEMHandler) GetCertificates(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	certs := make(map[string]string)
+	for _, signingKey := range h.keyStore.PublishedKeys() {
+		if len(signingKey.Certificates) == 0 {
+			continue
+		}
+
+		certs[signingKey.ID] = string(service.EncodeCertificates(signingKey.Certificates[0]))
+	}
+
+	if err := jsonMarshal(w, certs); err != nil {
+		h.logger.Errorf("Failed write JSON response: %v", err)
+	}
+}
+
+// GetCertificateChain returns the PEM-encoded certificate chain (leaf certificate first) of the key with the given ID
+// - used as "x5u". Staged keys are included.
+func (h *PEMHandler) GetCertificateChain(w http.ResponseWriter, _ *http.Request, kid string) {
+	_, signingKey, err := h.keyStore.FindKey(kid)
+	if err != nil || len(signingKey.Certificates) == 0 {
+		notFoundResponse(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-pem-file")
+
+	if _, err = w.Write(service.EncodeCertificates(signingKey.Certificates...)); err != nil {
+		h.logger.Errorf("Failed write PEM response: %v", err)
+	}
+}
+
+// GetPublicKey returns the PEM-encoded public key of the published key with the given ID.
+func (h *PEMHandler) GetPublicKey(w http.ResponseWriter, _ *http.Request, kid string) {
+	signingKey := findPublishedKey(h.keyStore.PublishedKeys(), kid)
+	if signingKey == nil || signingKey.IsSymmetric() {
+		notFoundResponse(w)
+		return
+	}
+
+	pemBytes, err := jwtmock.EncodePublicKey(signingKey.PublicKey, jwtmock.PublicKeyPEM)
+	if err != nil {
+		h.logger.Errorf("Failed to encode public key: %v", err)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+
+		if err = jsonMarshal(w, errorResponse{
+			Message: "Failed to encode public key",
+			Error:   err.Error(),
+		}); err != nil {
+			h.logger.Errorf("Failed write JSON response: %v", err)
+		}
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-pem-file")
+
+	if _, err = w.Write(pemBytes); err != nil {
+		h.logger.Errorf("Failed write PEM response: %v", err)
+	}
+}
+
+// findPublishedKey returns the key with the given ID - nil if it is not published.
+func findPublishedKey(keys []*jwtmock.SigningKey, kid string) *jwtmock.SigningKey {
+	for _, signingKey := range keys {
+		if signingKey.ID == kid {
+			return signingKey
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"net/http"
+
+	"github.com/nayyara-cropsey/jwtmock/log"
+)
+
+// SecretDefaultPath is the default path for the shared secret handler.
+const SecretDefaultPath = "/jwtmock/secret"
+
+type secretResponse struct {
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Secret    string `json:"secret"`
+}
+
+// SecretHandler provides handlers for retrieving the shared secret used to sign JWTs in HMAC mode.
+type SecretHandler struct {
+	keyStore keyStore
+	logger   *log.Logger
+}
+
+// NewSecretHandler is the preferred way to create a SecretHandler instance.
+func NewSecretHandler(keyStore keyStore, logger *log.Logger) *SecretHandler {
+	return &SecretHandler{
+		keyStore: keyStore,
+		logger:   logger,
+	}
+}
+
+// RegisterDefaultPaths registers the default paths for shared secret operations.
+func (h *SecretHandler) RegisterDefaultPaths(api *http.ServeMux) {
+	api.HandleFunc(SecretDefaultPath, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			h.Get(w, r)
+		default:
+			notFoundResponse(w)
+		}
+	})
+}
+
+// Get returns the current shared secret encoded as base64url (without padding) - the same encoding as JWK "k".
+func (h *SecretHandler) Get(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	signingKey := h.keyStore.GetSigningKey()
+	if !signingKey.IsSymmetric() {
+		w.WriteHeader(http.StatusNotFound)
+
+		if err := jsonMarshal(w, errorResponse{
+			Message: "No shared secret available",
+			Error:   "signing key is not symmetric: " + signingKey.Algorithm.String(),
+		}); err != nil {
+			h.logger.Errorf("Failed write JSON response: %v", err)
+		}
+
+		return
+	}
+
+	if err := jsonMarshal(w, secretResponse{
+		KeyID:     signingKey.ID,
+		Algorithm: signingKey.Algorithm.String(),
+		Secret:    base64.RawURLEncoding.EncodeToString(signingKey.Key.([]byte)),
+	}); err != nil {
+		h.logger.Errorf("Failed write JSON response: %v", err)
+	}
+}
@@ -0,0 +1,78 @@
+package jwks
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/nayyara-cropsey/jwtmock"
+)
+
+const (
+	encryptionUsage = "enc" // key is used for encryption only
+
+	// encryptionAlg is the key agreement algorithm published with encryption decoys
+	encryptionAlg = "ECDH-ES"
+
+	// unknownKeyType is the key type published with unknown key type decoys
+	unknownKeyType = "X-JWT-MOCK"
+)
+
+// NewDecoyJWK creates a decoy JWK from the public key and key ID of the given key - decoys are published in the
+// JWKS but must be ignored by consumers.
+func (t *Generator) NewDecoyJWK(decoy jwtmock.DecoyKey, key *jwtmock.SigningKey) (jwk.Key, error) {
+	decoyKey, err := jwk.New(key.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("jwk: %w", err)
+	}
+
+	vals := map[string]interface{}{
+		jwk.KeyIDKey:    key.ID,
+		jwk.KeyUsageKey: signingUsage,
+	}
+
+	switch decoy {
+	case jwtmock.DecoyEncryption:
+		vals[jwk.KeyUsageKey] = encryptionUsage
+		vals[jwk.AlgorithmKey] = encryptionAlg
+	case jwtmock.DecoyUnknownKeyType, jwtmock.DecoyDuplicateKeyID:
+		vals[jwk.AlgorithmKey] = key.Algorithm
+	case jwtmock.DecoyMissingAlgorithm:
+	default:
+		return nil, fmt.Errorf("unsupported decoy: %v", decoy)
+	}
+
+	for k, v := range vals {
+		if err = decoyKey.Set(k, v); err != nil {
+			return nil, fmt.Errorf("jwk field %v: %w", k, err)
+		}
+	}
+
+	if decoy == jwtmock.DecoyUnknownKeyType {
+		return &unknownTypeKey{Key: decoyKey}, nil
+	}
+
+	return decoyKey, nil
+}
+
+// unknownTypeKey is a JWK published with a key type that is not registered.
+type unknownTypeKey struct {
+	jwk.Key
+}
+
+// MarshalJSON marshals the key with the unknown key type.
+func (k *unknownTypeKey) MarshalJSON() ([]byte, error) {
+	keyJSON, err := json.Marshal(k.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]interface{}
+	if err = json.Unmarshal(keyJSON, &fields); err != nil {
+		return nil, err
+	}
+
+	fields[jwk.KeyTypeKey] = unknownKeyType
+
+	return json.Marshal(fields)
+}
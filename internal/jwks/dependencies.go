@@ -7,8 +7,8 @@ import (
 )
 
 type certGenerator interface {
-	CreateParent() (*x509.Certificate, error)
-	CreateChild(parent *x509.Certificate, key interface{}) (*x509.Certificate, error)
+	RootCertificate() *x509.Certificate
+	CreateChild(key interface{}) (*x509.Certificate, error)
 }
 
 type keyGenerator interface {
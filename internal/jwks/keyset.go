@@ -3,8 +3,9 @@ package jwks
 import (
 	// nolint:gosec //ignore warning about weak cryptographic primitive
 	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
 	"encoding/base64"
-	"encoding/hex"
 	"fmt"
 
 	"github.com/lestrrat-go/jwx/jwk"
@@ -13,69 +14,154 @@ import (
 
 const signingUsage = "sig" // key is used for signing only
 
-// Generator is atype for generating JWKS with a single singing key.
+// Generator is a type for generating signing keys and their JWKs.
 type Generator struct {
 	certGen certGenerator
 	keyGen  keyGenerator
 	keyLen  int
+	keyID   KeyIDFunc
+
+	publishSymmetric bool
+}
+
+// KeyIDFunc returns the key ID for a generated signing key.
+type KeyIDFunc func(signingKey *jwtmock.SigningKey) (string, error)
+
+// GeneratorOption allows setting options on the generator.
+type GeneratorOption func(*Generator)
+
+// WithSymmetricKeyPublishing option is used to publish symmetric (HMAC) keys in the JWKS as "oct" keys.
+// Symmetric keys are secret and omitted by default - publishing them is only useful for negative testing.
+func WithSymmetricKeyPublishing(publish bool) GeneratorOption {
+	return func(g *Generator) {
+		g.publishSymmetric = publish
+	}
+}
+
+// WithKeyIDFunc option is used to choose the key ID of generated keys - the ID set by the key generator is used
+// by default.
+func WithKeyIDFunc(keyID KeyIDFunc) GeneratorOption {
+	return func(g *Generator) {
+		g.keyID = keyID
+	}
 }
 
 // NewGenerator is the preferred way to instantiate a key generator.
-func NewGenerator(certGen certGenerator, keyGen keyGenerator, keyLen int) *Generator {
-	return &Generator{
+func NewGenerator(certGen certGenerator, keyGen keyGenerator, keyLen int, options ...GeneratorOption) *Generator {
+	g := &Generator{
 		certGen: certGen,
 		keyGen:  keyGen,
 		keyLen:  keyLen,
 	}
+
+	for _, option := range options {
+		option(g)
+	}
+
+	return g
 }
 
-// GenerateJWKSet generates a JSON web key set for use in signing tokens.
-func (t *Generator) GenerateJWKSet() (*jwk.Set, *jwtmock.SigningKey, error) {
-	signingKey, err := t.keyGen.GenerateKey(t.keyLen)
+// GenerateJWK generates a signing key and its public JWK using the default key generator - see GenerateJWKWith.
+func (t *Generator) GenerateJWK() (jwk.Key, *jwtmock.SigningKey, error) {
+	return t.GenerateJWKWith(t.keyGen)
+}
+
+// GenerateJWKWith generates a signing key using the given key generator, sets its key ID and creates its JWK with
+// NewJWK.
+func (t *Generator) GenerateJWKWith(keyGen keyGenerator) (jwk.Key, *jwtmock.SigningKey, error) {
+	signingKey, err := keyGen.GenerateKey(t.keyLen)
 	if err != nil {
 		return nil, nil, fmt.Errorf("generate key: %w", err)
 	}
 
-	parentCert, err := t.certGen.CreateParent()
-	if err != nil {
-		return nil, nil, fmt.Errorf("parent cert: %w", err)
+	if t.keyID != nil {
+		if signingKey.ID, err = t.keyID(signingKey); err != nil {
+			return nil, nil, fmt.Errorf("key ID: %w", err)
+		}
 	}
 
-	cert, err := t.certGen.CreateChild(parentCert, signingKey.Key)
+	key, err := t.NewJWK(signingKey)
 	if err != nil {
-		return nil, nil, fmt.Errorf("cert: %w", err)
+		return nil, nil, err
+	}
+
+	return key, signingKey, nil
+}
+
+// NewJWK creates the public JWK for an existing signing key - a certificate signed by the root CA is generated for
+// the key if it has none. Symmetric keys get no JWK (nil) unless symmetric key publishing is enabled.
+func (t *Generator) NewJWK(signingKey *jwtmock.SigningKey) (jwk.Key, error) {
+	if signingKey.IsSymmetric() {
+		return t.symmetricJWK(signingKey)
+	}
+
+	if len(signingKey.Certificates) == 0 {
+		cert, err := t.certGen.CreateChild(signingKey.Key)
+		if err != nil {
+			return nil, fmt.Errorf("cert: %w", err)
+		}
+
+		signingKey.Certificates = []*x509.Certificate{cert, t.certGen.RootCertificate()}
 	}
 
 	// generate JWK from signing key
 	// generate JWK public key
 	key, err := jwk.New(signingKey.PublicKey)
 	if err != nil {
-		return nil, nil, fmt.Errorf("jwk: %w", err)
+		return nil, fmt.Errorf("jwk: %w", err)
 	}
 
-	// encode cert as base-64 string
-	certStr := base64.StdEncoding.EncodeToString(cert.Raw)
+	// encode certs as base-64 strings
+	certStrs := make([]string, 0, len(signingKey.Certificates))
+	for _, cert := range signingKey.Certificates {
+		certStrs = append(certStrs, base64.StdEncoding.EncodeToString(cert.Raw))
+	}
 
+	// thumbprints are base64url-encoded digests of the DER-encoded leaf certificate (RFC 7517 section 4.8 and 4.9)
 	// nolint:gosec // ignore weak cryptographic algorithm warning
-	hash := sha1.New()
-	hash.Write(cert.Raw)
-	x5tSHA1 := hex.EncodeToString(hash.Sum(nil))
+	x5tSHA1 := sha1.Sum(signingKey.Certificates[0].Raw)
+	x5tSHA256 := sha256.Sum256(signingKey.Certificates[0].Raw)
 
 	vals := map[string]interface{}{
-		jwk.KeyIDKey:              signingKey.ID,
-		jwk.X509CertThumbprintKey: x5tSHA1,
-		jwk.X509CertChainKey:      []string{certStr},
-		jwk.KeyUsageKey:           signingUsage,
-		jwk.AlgorithmKey:          signingKey.Algorithm,
+		jwk.KeyIDKey:                  signingKey.ID,
+		jwk.X509CertThumbprintKey:     base64.RawURLEncoding.EncodeToString(x5tSHA1[:]),
+		jwk.X509CertThumbprintS256Key: base64.RawURLEncoding.EncodeToString(x5tSHA256[:]),
+		jwk.X509CertChainKey:          certStrs,
+		jwk.KeyUsageKey:               signingUsage,
+		jwk.AlgorithmKey:              signingKey.Algorithm,
 	}
 
 	for k, v := range vals {
 		if err = key.Set(k, v); err != nil {
-			return nil, nil, fmt.Errorf("jwk field %v: %w", k, err)
+			return nil, fmt.Errorf("jwk field %v: %w", k, err)
 		}
 	}
 
-	keySet := &jwk.Set{Keys: []jwk.Key{key}}
+	return key, nil
+}
+
+// symmetricJWK generates a JWK for a symmetric signing key - it is nil unless publishing is enabled.
+func (t *Generator) symmetricJWK(signingKey *jwtmock.SigningKey) (jwk.Key, error) {
+	if !t.publishSymmetric {
+		return nil, nil
+	}
+
+	key, err := jwk.New(signingKey.Key)
+	if err != nil {
+		return nil, fmt.Errorf("jwk: %w", err)
+	}
+
+	vals := map[string]interface{}{
+		jwk.KeyIDKey:     signingKey.ID,
+		jwk.KeyUsageKey:  signingUsage,
+		jwk.AlgorithmKey: signingKey.Algorithm,
+	}
+
+	for k, v := range vals {
+		if err = key.Set(k, v); err != nil {
+			return nil, fmt.Errorf("jwk field %v: %w", k, err)
+		}
+	}
 
-	return keySet, signingKey, nil
+	return key, nil
 }
@@ -1,64 +1,148 @@
 package service
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rand"
-	"crypto/rsa"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/pem"
 	"errors"
 	"fmt"
-	"math/big"
 	"time"
 )
 
 const (
-	orgName     = "JWT Mock"
-	randIntSize = 100
+	orgName       = "JWT Mock"
+	rootName      = "JWT Mock Root CA"
+	serialNumBits = 128
+
+	// the root CA outlives any certificate it signs
+	rootLifeTime = 10 * 365 * 24 * time.Hour
 )
 
-// CertificateGenerator is used to generate certificates.
+// CertificateGenerator is used to generate certificates signed by its own root CA.
 type CertificateGenerator struct {
 	lifeTime time.Duration
+	source   *Source
+
+	rootKey  crypto.Signer
+	rootCert *x509.Certificate
 }
 
 // NewCertificateGenerator is the preferred way to instantiate a certificate generator.
-func NewCertificateGenerator(lifeTime time.Duration) *CertificateGenerator {
-	return &CertificateGenerator{lifeTime: lifeTime}
-}
+// A new root CA key and self-signed root certificate are generated for the generator using the given source.
+func NewCertificateGenerator(lifeTime time.Duration, source *Source) (*CertificateGenerator, error) {
+	rootKey, err := newRootKey(source)
+	if err != nil {
+		return nil, fmt.Errorf("root key: %w", err)
+	}
 
-// CreateParent generates a X.509 parent/root certificate for use in authorization servers.
-func (c *CertificateGenerator) CreateParent() (*x509.Certificate, error) {
-	serialNumber, err := rand.Int(rand.Reader, big.NewInt(randIntSize))
+	serialNumber, err := source.Int(serialNumBits)
 	if err != nil {
 		return nil, fmt.Errorf("serial number: %w", err)
 	}
 
-	return &x509.Certificate{
+	now := source.Now()
+	template := &x509.Certificate{
 		SerialNumber: serialNumber,
 		Subject: pkix.Name{
 			Organization: []string{orgName},
+			CommonName:   rootName,
 		},
-		NotBefore: time.Now(),
-		NotAfter:  time.Now().Add(c.lifeTime),
-		KeyUsage:  x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
-		ExtKeyUsage: []x509.ExtKeyUsage{
-			x509.ExtKeyUsageServerAuth,
-		},
+		NotBefore:             now,
+		NotAfter:              now.Add(rootLifeTime),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
 		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	derBytes, err := x509.CreateCertificate(rand.Reader, template, template, rootKey.Public(), rootKey)
+	if err != nil {
+		return nil, fmt.Errorf("create root: %w", err)
+	}
+
+	rootCert, err := x509.ParseCertificate(derBytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse root: %w", err)
+	}
+
+	return &CertificateGenerator{
+		lifeTime: lifeTime,
+		source:   source,
+		rootKey:  rootKey,
+		rootCert: rootCert,
 	}, nil
 }
 
-// CreateChild - creates a child certificate with given key and parent certificate.
-func (c *CertificateGenerator) CreateChild(parent *x509.Certificate, key interface{}) (*x509.Certificate, error) {
-	rsaKey, ok := key.(*rsa.PrivateKey)
+// RootCertificate returns the root CA certificate that signs all generated certificates.
+func (c *CertificateGenerator) RootCertificate() *x509.Certificate {
+	return c.rootCert
+}
+
+// CreateChild creates a leaf certificate for the given key signed by the root CA.
+func (c *CertificateGenerator) CreateChild(key interface{}) (*x509.Certificate, error) {
+	signer, ok := key.(crypto.Signer)
 	if !ok {
-		return nil, errors.New("must be an RSA Key")
+		return nil, errors.New("must be an RSA, ECDSA or Ed25519 Key")
+	}
+
+	serialNumber, err := c.source.Int(serialNumBits)
+	if err != nil {
+		return nil, fmt.Errorf("serial number: %w", err)
 	}
 
-	derBytes, err := x509.CreateCertificate(rand.Reader, parent, parent, &rsaKey.PublicKey, rsaKey)
+	now := c.source.Now()
+	notAfter := now.Add(c.lifeTime)
+	if notAfter.After(c.rootCert.NotAfter) {
+		notAfter = c.rootCert.NotAfter
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject: pkix.Name{
+			Organization: []string{orgName},
+		},
+		NotBefore: now,
+		NotAfter:  notAfter,
+		KeyUsage:  x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{
+			x509.ExtKeyUsageServerAuth,
+		},
+		BasicConstraintsValid: true,
+	}
+
+	derBytes, err := x509.CreateCertificate(rand.Reader, template, c.rootCert, signer.Public(), c.rootKey)
 	if err != nil {
 		return nil, fmt.Errorf("create: %w", err)
 	}
 
 	return x509.ParseCertificate(derBytes)
 }
+
+// EncodeCertificates returns the PEM encoding of the given certificates.
+func EncodeCertificates(certs ...*x509.Certificate) []byte {
+	var pemBytes []byte
+	for _, cert := range certs {
+		pemBytes = append(pemBytes, pem.EncodeToMemory(&pem.Block{Type: pemBlockCertificate, Bytes: cert.Raw})...)
+	}
+
+	return pemBytes
+}
+
+// newRootKey generates the root CA key - an Ed25519 key for deterministic sources since ECDSA signatures (and so
+// certificates) are always randomized.
+func newRootKey(source *Source) (crypto.Signer, error) {
+	if !source.Deterministic() {
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	}
+
+	seed := make([]byte, ed25519.SeedSize)
+	if _, err := source.Read(seed); err != nil {
+		return nil, err
+	}
+
+	return ed25519.NewKeyFromSeed(seed), nil
+}
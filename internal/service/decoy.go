@@ -0,0 +1,97 @@
+package service
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/nayyara-cropsey/jwtmock"
+	"github.com/nayyara-cropsey/jwtmock/internal/jwks"
+)
+
+// ErrUnsupportedDecoy means the decoy key is not supported.
+var ErrUnsupportedDecoy = errors.New("unsupported decoy key")
+
+// decoyKey is a decoy published in the JWKS along with the keys it is generated from.
+type decoyKey struct {
+	kind jwtmock.DecoyKey
+
+	// keys are ECDSA and Ed25519 keys - duplicate key ID decoys use the one with another key type than the
+	// current signing key.
+	ecKey *jwtmock.SigningKey
+	edKey *jwtmock.SigningKey
+
+	// jwk is the published decoy - for duplicate key ID decoys it is recreated once the current key ID changes.
+	jwk jwk.Key
+	kid string
+}
+
+// newDecoyKey generates the keys for a decoy of the given kind.
+func newDecoyKey(kind jwtmock.DecoyKey, source *Source) (*decoyKey, error) {
+	switch kind {
+	case jwtmock.DecoyEncryption, jwtmock.DecoyUnknownKeyType, jwtmock.DecoyMissingAlgorithm,
+		jwtmock.DecoyDuplicateKeyID:
+	default:
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedDecoy, kind)
+	}
+
+	d := &decoyKey{kind: kind}
+
+	ecKeyGen, err := NewECKeyGenerator(jwa.ES256, source)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.ecKey, err = ecKeyGen.GenerateKey(0); err != nil {
+		return nil, err
+	}
+
+	if kind == jwtmock.DecoyDuplicateKeyID {
+		if d.edKey, err = NewEd25519KeyGenerator(source).GenerateKey(0); err != nil {
+			return nil, err
+		}
+	}
+
+	return d, nil
+}
+
+// decoyJWK returns the published decoy - a duplicate kid decoy copies the kid of newest, the latest published key.
+func (d *decoyKey) decoyJWK(generator *jwks.Generator, newest *jwtmock.SigningKey) (jwk.Key, error) {
+	if d.kind != jwtmock.DecoyDuplicateKeyID {
+		if d.jwk == nil {
+			key, err := generator.NewDecoyJWK(d.kind, d.ecKey)
+			if err != nil {
+				return nil, err
+			}
+
+			d.jwk = key
+		}
+
+		return d.jwk, nil
+	}
+
+	if d.jwk != nil && d.kid == newest.ID {
+		return d.jwk, nil
+	}
+
+	source := d.ecKey
+	if _, ok := newest.Key.(*ecdsa.PrivateKey); ok {
+		source = d.edKey
+	}
+
+	key, err := generator.NewDecoyJWK(d.kind, &jwtmock.SigningKey{
+		ID:        newest.ID,
+		Key:       source.Key,
+		Algorithm: source.Algorithm,
+		PublicKey: source.PublicKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	d.jwk, d.kid = key, newest.ID
+
+	return key, nil
+}
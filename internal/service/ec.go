@@ -0,0 +1,92 @@
+package service
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/nayyara-cropsey/jwtmock"
+)
+
+// ecCurves maps ECDSA signing algorithms to the curve required by RFC 7518.
+var ecCurves = map[jwa.SignatureAlgorithm]elliptic.Curve{
+	jwa.ES256: elliptic.P256(),
+	jwa.ES384: elliptic.P384(),
+	jwa.ES512: elliptic.P521(),
+}
+
+// ECKeyGenerator generates key IDs and ECDSA keys.
+type ECKeyGenerator struct {
+	algorithm jwa.SignatureAlgorithm
+	curve     elliptic.Curve
+	source    *Source
+}
+
+// NewECKeyGenerator is the preferred way to create an ECDSA key generator for one of ES256, ES384 or ES512.
+func NewECKeyGenerator(algorithm jwa.SignatureAlgorithm, source *Source) (*ECKeyGenerator, error) {
+	curve, ok := ecCurves[algorithm]
+	if !ok {
+		return nil, fmt.Errorf("%w: %v is not an ECDSA algorithm", ErrUnsupportedAlgorithm, algorithm)
+	}
+
+	return &ECKeyGenerator{
+		algorithm: algorithm,
+		curve:     curve,
+		source:    source,
+	}, nil
+}
+
+// GenerateKey generates an ECDSA signing key - the key length is determined by the curve so length is ignored.
+func (k *ECKeyGenerator) GenerateKey(_ int) (*jwtmock.SigningKey, error) {
+	id, err := k.source.ID(idLen)
+	if err != nil {
+		return nil, err
+	}
+
+	var key *ecdsa.PrivateKey
+	if k.source.Deterministic() {
+		key, err = deterministicECKey(k.curve, k.source)
+	} else {
+		key, err = ecdsa.GenerateKey(k.curve, rand.Reader)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &jwtmock.SigningKey{
+		ID:        id,
+		Key:       key,
+		Algorithm: k.algorithm,
+		PublicKey: &key.PublicKey,
+	}, nil
+}
+
+// deterministicECKey generates an ECDSA key which only depends on the bytes read from random - ecdsa.GenerateKey
+// always uses a secure source of randomness. The private scalar is derived as in FIPS 186-4 B.4.1.
+func deterministicECKey(curve elliptic.Curve, random io.Reader) (*ecdsa.PrivateKey, error) {
+	params := curve.Params()
+	b := make([]byte, params.BitSize/8+8)
+	if _, err := io.ReadFull(random, b); err != nil {
+		return nil, err
+	}
+
+	one := big.NewInt(1)
+	d := new(big.Int).SetBytes(b)
+	d.Mod(d, new(big.Int).Sub(params.N, one))
+	d.Add(d, one)
+
+	key := &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{Curve: curve},
+		D:         d,
+	}
+
+	// nolint:staticcheck // the only way to derive the public key from a scalar before Go 1.25
+	key.X, key.Y = curve.ScalarBaseMult(d.FillBytes(make([]byte, (params.BitSize+7)/8)))
+
+	return key, nil
+}
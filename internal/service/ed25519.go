@@ -0,0 +1,40 @@
+package service
+
+import (
+	"crypto/ed25519"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/nayyara-cropsey/jwtmock"
+)
+
+// Ed25519KeyGenerator generates key IDs and Ed25519 keys for EdDSA signing.
+type Ed25519KeyGenerator struct {
+	source *Source
+}
+
+// NewEd25519KeyGenerator is the preferred way to create an Ed25519 key generator.
+func NewEd25519KeyGenerator(source *Source) *Ed25519KeyGenerator {
+	return &Ed25519KeyGenerator{source: source}
+}
+
+// GenerateKey generates an Ed25519 signing key - Ed25519 keys have a fixed size so length is ignored.
+func (k *Ed25519KeyGenerator) GenerateKey(_ int) (*jwtmock.SigningKey, error) {
+	id, err := k.source.ID(idLen)
+	if err != nil {
+		return nil, err
+	}
+
+	seed := make([]byte, ed25519.SeedSize)
+	if _, err = k.source.Read(seed); err != nil {
+		return nil, err
+	}
+
+	key := ed25519.NewKeyFromSeed(seed)
+
+	return &jwtmock.SigningKey{
+		ID:        id,
+		Key:       key,
+		Algorithm: jwa.EdDSA,
+		PublicKey: key.Public(),
+	}, nil
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/nayyara-cropsey/jwtmock"
+)
+
+// ExportSigningKey exports the private signing material of a key as PEM and as a private JWK.
+func ExportSigningKey(signingKey *jwtmock.SigningKey) (*jwtmock.PrivateKeyExport, error) {
+	key, err := jwk.New(signingKey.Key)
+	if err != nil {
+		return nil, fmt.Errorf("jwk: %w", err)
+	}
+
+	vals := map[string]interface{}{
+		jwk.KeyIDKey:     signingKey.ID,
+		jwk.KeyUsageKey:  "sig",
+		jwk.AlgorithmKey: signingKey.Algorithm,
+	}
+
+	for k, v := range vals {
+		if err = key.Set(k, v); err != nil {
+			return nil, fmt.Errorf("jwk field %v: %w", k, err)
+		}
+	}
+
+	jwkJSON, err := json.Marshal(key)
+	if err != nil {
+		return nil, fmt.Errorf("jwk marshal: %w", err)
+	}
+
+	export := &jwtmock.PrivateKeyExport{
+		ID:        signingKey.ID,
+		Algorithm: signingKey.Algorithm.String(),
+		JWK:       jwkJSON,
+	}
+
+	if !signingKey.IsSymmetric() {
+		der, err := x509.MarshalPKCS8PrivateKey(signingKey.Key)
+		if err != nil {
+			return nil, fmt.Errorf("pem: %w", err)
+		}
+
+		export.PEM = string(pem.EncodeToMemory(&pem.Block{Type: pemBlockPrivateKey, Bytes: der}))
+	}
+
+	return export, nil
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/nayyara-cropsey/jwtmock"
+)
+
+// hmacSecretSizes maps HMAC signing algorithms to the secret size (in bytes) matching their hash output.
+var hmacSecretSizes = map[jwa.SignatureAlgorithm]int{
+	jwa.HS256: 32,
+	jwa.HS384: 48,
+	jwa.HS512: 64,
+}
+
+// HMACKeyGenerator generates key IDs and shared secrets for HMAC signing.
+type HMACKeyGenerator struct {
+	algorithm jwa.SignatureAlgorithm
+	secret    []byte
+	source    *Source
+}
+
+// NewHMACKeyGenerator is the preferred way to create an HMAC key generator for one of HS256, HS384 or HS512.
+// Every generated key uses the given secret - a random secret is generated per key when it is empty.
+func NewHMACKeyGenerator(algorithm jwa.SignatureAlgorithm, secret []byte, source *Source) (*HMACKeyGenerator, error) {
+	if _, ok := hmacSecretSizes[algorithm]; !ok {
+		return nil, fmt.Errorf("%w: %v is not an HMAC algorithm", ErrUnsupportedAlgorithm, algorithm)
+	}
+
+	return &HMACKeyGenerator{
+		algorithm: algorithm,
+		secret:    secret,
+		source:    source,
+	}, nil
+}
+
+// GenerateKey generates an HMAC signing key - the secret size is determined by the algorithm so length is ignored.
+func (k *HMACKeyGenerator) GenerateKey(_ int) (*jwtmock.SigningKey, error) {
+	id, err := k.source.ID(idLen)
+	if err != nil {
+		return nil, err
+	}
+
+	secret := k.secret
+	if len(secret) == 0 {
+		secret = make([]byte, hmacSecretSizes[k.algorithm])
+		if _, err = k.source.Read(secret); err != nil {
+			return nil, err
+		}
+	}
+
+	return &jwtmock.SigningKey{
+		ID:        id,
+		Key:       secret,
+		Algorithm: k.algorithm,
+	}, nil
+}
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/nayyara-cropsey/jwtmock"
+)
+
+// ErrUnsupportedAlgorithm means no key generator exists for the requested signing algorithm.
+var ErrUnsupportedAlgorithm = errors.New("unsupported signing algorithm")
+
+// KeyGenerator generates signing keys for a single signing algorithm.
+type KeyGenerator interface {
+	GenerateKey(length int) (*jwtmock.SigningKey, error)
+}
+
+// NewKeyGenerator returns a key generator for the given signing algorithm using the given source of randomness.
+func NewKeyGenerator(algorithm jwa.SignatureAlgorithm, source *Source) (KeyGenerator, error) {
+	switch algorithm {
+	case jwa.RS256, jwa.RS384, jwa.RS512, jwa.PS256, jwa.PS384, jwa.PS512:
+		return NewRSAKeyGenerator(algorithm, source)
+	case jwa.ES256, jwa.ES384, jwa.ES512:
+		return NewECKeyGenerator(algorithm, source)
+	case jwa.EdDSA:
+		return NewEd25519KeyGenerator(source), nil
+	case jwa.HS256, jwa.HS384, jwa.HS512:
+		return NewHMACKeyGenerator(algorithm, nil, source)
+	default:
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedAlgorithm, algorithm)
+	}
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"crypto"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/nayyara-cropsey/jwtmock"
+	"github.com/nayyara-cropsey/jwtmock/internal/jwks"
+)
+
+// ErrUnsupportedKeyIDStrategy means the key ID strategy is not supported.
+var ErrUnsupportedKeyIDStrategy = errors.New("unsupported key ID strategy")
+
+// NewKeyIDFunc returns a function that chooses the key ID of generated keys using the given strategy - random
+// (the default if empty), thumbprint or fixed. The fixed ID is only used with the fixed strategy, the prefix is
+// prepended to the key ID for all strategies.
+func NewKeyIDFunc(strategy jwtmock.KeyIDStrategy, fixedID, prefix string) (jwks.KeyIDFunc, error) {
+	var keyID jwks.KeyIDFunc
+
+	switch strategy {
+	case "", jwtmock.KeyIDRandom:
+		keyID = func(signingKey *jwtmock.SigningKey) (string, error) {
+			return signingKey.ID, nil
+		}
+	case jwtmock.KeyIDThumbprint:
+		keyID = func(signingKey *jwtmock.SigningKey) (string, error) {
+			if signingKey.IsSymmetric() {
+				return thumbprintID(signingKey.Key)
+			}
+
+			return thumbprintID(signingKey.PublicKey)
+		}
+	case jwtmock.KeyIDFixed:
+		if fixedID == "" {
+			return nil, fmt.Errorf("%w: fixed key ID is empty", ErrUnsupportedKeyIDStrategy)
+		}
+
+		keyID = func(*jwtmock.SigningKey) (string, error) {
+			return fixedID, nil
+		}
+	default:
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedKeyIDStrategy, strategy)
+	}
+
+	if prefix == "" {
+		return keyID, nil
+	}
+
+	return func(signingKey *jwtmock.SigningKey) (string, error) {
+		kid, err := keyID(signingKey)
+		if err != nil {
+			return "", err
+		}
+
+		return prefix + kid, nil
+	}, nil
+}
+
+// thumbprintID returns the base64url-encoded RFC 7638 SHA-256 thumbprint of the key.
+func thumbprintID(key interface{}) (string, error) {
+	jwKey, err := jwk.New(key)
+	if err != nil {
+		return "", err
+	}
+
+	thumbprint, err := jwKey.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(thumbprint), nil
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/nayyara-cropsey/jwtmock"
+)
+
+const (
+	pemBlockCertificate = "CERTIFICATE"
+	pemBlockRSAKey      = "RSA PRIVATE KEY" // PKCS #1
+	pemBlockECKey       = "EC PRIVATE KEY"  // SEC 1
+	pemBlockPrivateKey  = "PRIVATE KEY"     // PKCS #8
+)
+
+var (
+	// ErrKeyCertMismatch means a certificate does not match the private key it was loaded with.
+	ErrKeyCertMismatch = errors.New("certificate does not match private key")
+
+	// ErrInvalidPEM means no usable PEM block was found.
+	ErrInvalidPEM = errors.New("invalid PEM")
+)
+
+// publicKey is implemented by all public keys in the standard library.
+type publicKey interface {
+	Equal(crypto.PublicKey) bool
+}
+
+// LoadSigningKeyFiles loads a signing key from a PEM-encoded private key file and an optional PEM-encoded
+// certificate chain file (leaf certificate first). See LoadSigningKey.
+func LoadSigningKeyFiles(keyFile, certFile string, alg jwa.SignatureAlgorithm, kid string) (*jwtmock.SigningKey, error) {
+	keyPEM, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("read key: %w", err)
+	}
+
+	var certPEM []byte
+	if certFile != "" {
+		if certPEM, err = os.ReadFile(certFile); err != nil {
+			return nil, fmt.Errorf("read certificate: %w", err)
+		}
+	}
+
+	return LoadSigningKey(keyPEM, certPEM, alg, kid)
+}
+
+// LoadSigningKey loads a signing key from a PEM-encoded RSA, ECDSA or Ed25519 private key and an optional
+// PEM-encoded certificate chain (leaf certificate first). The algorithm is derived from the key if not given,
+// otherwise it must be compatible with the key. The leaf certificate must match the private key.
+// The key ID is the RFC 7638 thumbprint of the key if not given so that it is the same every time the key is loaded.
+func LoadSigningKey(keyPEM, certPEM []byte, alg jwa.SignatureAlgorithm, kid string) (*jwtmock.SigningKey, error) {
+	key, err := parsePrivateKey(keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("private key: %w", err)
+	}
+
+	if alg, err = keyAlgorithm(key, alg); err != nil {
+		return nil, err
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported key type %T", ErrInvalidPEM, key)
+	}
+
+	var certs []*x509.Certificate
+	if len(certPEM) > 0 {
+		if certs, err = parseCertificates(certPEM); err != nil {
+			return nil, fmt.Errorf("certificate: %w", err)
+		}
+
+		if pub, ok := certs[0].PublicKey.(publicKey); !ok || !pub.Equal(signer.Public()) {
+			return nil, ErrKeyCertMismatch
+		}
+	}
+
+	if kid == "" {
+		if kid, err = thumbprintID(signer.Public()); err != nil {
+			return nil, fmt.Errorf("key ID: %w", err)
+		}
+	}
+
+	return &jwtmock.SigningKey{
+		ID:           kid,
+		Key:          key,
+		Algorithm:    alg,
+		PublicKey:    signer.Public(),
+		Certificates: certs,
+	}, nil
+}
+
+// parsePrivateKey parses the first private key PEM block in PKCS #1, SEC 1 or PKCS #8 form.
+func parsePrivateKey(keyPEM []byte) (interface{}, error) {
+	for block, rest := pem.Decode(keyPEM); block != nil; block, rest = pem.Decode(rest) {
+		switch block.Type {
+		case pemBlockRSAKey:
+			return x509.ParsePKCS1PrivateKey(block.Bytes)
+		case pemBlockECKey:
+			return x509.ParseECPrivateKey(block.Bytes)
+		case pemBlockPrivateKey:
+			return x509.ParsePKCS8PrivateKey(block.Bytes)
+		}
+	}
+
+	return nil, fmt.Errorf("%w: no private key found", ErrInvalidPEM)
+}
+
+// parseCertificates parses all certificate PEM blocks in order.
+func parseCertificates(certPEM []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for block, rest := pem.Decode(certPEM); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != pemBlockCertificate {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+
+		certs = append(certs, cert)
+	}
+
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("%w: no certificate found", ErrInvalidPEM)
+	}
+
+	return certs, nil
+}
+
+// keyAlgorithm returns the signing algorithm for the key - checking that the given algorithm (if any) can be used.
+func keyAlgorithm(key interface{}, alg jwa.SignatureAlgorithm) (jwa.SignatureAlgorithm, error) {
+	var (
+		defaultAlg jwa.SignatureAlgorithm
+		compatible bool
+	)
+
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		defaultAlg, compatible = jwa.RS256, rsaAlgorithms[alg]
+	case *ecdsa.PrivateKey:
+		for ecAlg, curve := range ecCurves {
+			if curve == k.Curve {
+				defaultAlg = ecAlg
+			}
+		}
+
+		compatible = alg == defaultAlg
+	case ed25519.PrivateKey:
+		defaultAlg, compatible = jwa.EdDSA, alg == jwa.EdDSA
+	default:
+		return "", fmt.Errorf("%w: unsupported key type %T", ErrInvalidPEM, key)
+	}
+
+	if defaultAlg == "" {
+		return "", fmt.Errorf("%w: unsupported curve", ErrUnsupportedAlgorithm)
+	}
+
+	if alg == "" {
+		return defaultAlg, nil
+	}
+
+	if !compatible {
+		return "", fmt.Errorf("%w: %v cannot be used with %T", ErrUnsupportedAlgorithm, alg, key)
+	}
+
+	return alg, nil
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"runtime"
+	"sync"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/nayyara-cropsey/jwtmock"
+)
+
+// defaultPoolSize is the number of keys kept ready per algorithm and key length by the default key pool.
+const defaultPoolSize = 8
+
+// DefaultKeyPool is the process-wide key pool - with a background worker per CPU that Go code may run on.
+var DefaultKeyPool = NewKeyPool(defaultPoolSize, runtime.GOMAXPROCS(0))
+
+// poolKey identifies the keys of a pool queue.
+type poolKey struct {
+	algorithm jwa.SignatureAlgorithm
+	length    int
+}
+
+// KeyPool generates signing keys ahead of time in the background so that taking a key is fast.
+// Keys are generated for an algorithm and key length once the pool is warmed or they are first requested - up to size
+// keys are kept ready.
+// It is safe for concurrent use.
+type KeyPool struct {
+	size    int
+	workers int
+
+	queues map[poolKey]chan *jwtmock.SigningKey
+	m      sync.Mutex
+
+	shared  map[poolKey]*jwtmock.SigningKey
+	sharedM sync.Mutex
+}
+
+// NewKeyPool is the preferred way to instantiate a key pool which keeps size keys ready per algorithm and key length,
+// generated by the given number of background workers.
+func NewKeyPool(size, workers int) *KeyPool {
+	return &KeyPool{
+		size:    size,
+		workers: workers,
+		queues:  make(map[poolKey]chan *jwtmock.SigningKey),
+		shared:  make(map[poolKey]*jwtmock.SigningKey),
+	}
+}
+
+// KeyGenerator returns a key generator for the given algorithm that takes keys from the pool.
+// A key is generated on demand if none is ready.
+func (p *KeyPool) KeyGenerator(algorithm jwa.SignatureAlgorithm) (KeyGenerator, error) {
+	keyGen, err := NewKeyGenerator(algorithm, NewSource())
+	if err != nil {
+		return nil, err
+	}
+
+	return &pooledKeyGenerator{pool: p, algorithm: algorithm, keyGen: keyGen}, nil
+}
+
+// Warm starts the background workers that fill the pool with keys for the given algorithm and key length, so that
+// keys are ready by the time they are taken.
+func (p *KeyPool) Warm(algorithm jwa.SignatureAlgorithm, length int) error {
+	keyGen, err := NewKeyGenerator(algorithm, NewSource())
+	if err != nil {
+		return err
+	}
+
+	p.queue(poolKey{algorithm: algorithm, length: length}, keyGen)
+
+	return nil
+}
+
+// SharedKey returns a copy of a key for the given algorithm and key length which is the same for every call - the
+// key is generated on first use, without starting the background workers. Certificates are not shared.
+func (p *KeyPool) SharedKey(algorithm jwa.SignatureAlgorithm, length int) (*jwtmock.SigningKey, error) {
+	id := poolKey{algorithm: algorithm, length: length}
+
+	p.sharedM.Lock()
+	defer p.sharedM.Unlock()
+
+	signingKey, ok := p.shared[id]
+	if !ok {
+		keyGen, err := NewKeyGenerator(algorithm, NewSource())
+		if err != nil {
+			return nil, err
+		}
+
+		if signingKey, err = keyGen.GenerateKey(length); err != nil {
+			return nil, err
+		}
+
+		p.shared[id] = signingKey
+	}
+
+	return &jwtmock.SigningKey{
+		ID:        signingKey.ID,
+		Key:       signingKey.Key,
+		Algorithm: signingKey.Algorithm,
+		PublicKey: signingKey.PublicKey,
+	}, nil
+}
+
+// queue returns the queue of ready keys - starting the background workers that fill it on first use.
+func (p *KeyPool) queue(id poolKey, keyGen KeyGenerator) chan *jwtmock.SigningKey {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	queue, ok := p.queues[id]
+	if ok {
+		return queue
+	}
+
+	queue = make(chan *jwtmock.SigningKey, p.size)
+	p.queues[id] = queue
+
+	for i := 0; i < p.workers; i++ {
+		go func() {
+			for {
+				signingKey, err := keyGen.GenerateKey(id.length)
+				if err != nil {
+					// keys are generated on demand instead
+					return
+				}
+
+				queue <- signingKey
+			}
+		}()
+	}
+
+	return queue
+}
+
+// pooledKeyGenerator takes keys from a key pool.
+type pooledKeyGenerator struct {
+	pool      *KeyPool
+	algorithm jwa.SignatureAlgorithm
+	keyGen    KeyGenerator
+}
+
+// GenerateKey takes a key from the pool - or generates one if none is ready.
+func (k *pooledKeyGenerator) GenerateKey(length int) (*jwtmock.SigningKey, error) {
+	select {
+	case signingKey := <-k.pool.queue(poolKey{algorithm: k.algorithm, length: length}, k.keyGen):
+		return signingKey, nil
+	default:
+		return k.keyGen.GenerateKey(length)
+	}
+}
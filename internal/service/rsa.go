@@ -3,33 +3,69 @@ package service
 import (
 	"crypto/rand"
 	"crypto/rsa"
-	mrand "math/rand"
-	"time"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
 
 	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/nayyara-cropsey/jwtmock"
 )
 
-// idLen is the ID length
-const idLen = 16
+const (
+	// idLen is the ID length
+	idLen = 16
 
-// ID runes contains characters for generating an ID
-var idRunes = []rune("123456abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	// rsaExponent is the public exponent of generated RSA keys
+	rsaExponent = 65537
+
+	// primality tests are repeated this many times for deterministic RSA keys
+	primeRounds = 20
+)
+
+// rsaAlgorithms contains the signing algorithms that use RSA keys - PKCS #1 v1.5 (RS*) and PSS (PS*) variants.
+var rsaAlgorithms = map[jwa.SignatureAlgorithm]bool{
+	jwa.RS256: true,
+	jwa.RS384: true,
+	jwa.RS512: true,
+	jwa.PS256: true,
+	jwa.PS384: true,
+	jwa.PS512: true,
+}
 
 // RSAKeyGenerator generates key IDs and keys.
-type RSAKeyGenerator struct{}
+type RSAKeyGenerator struct {
+	algorithm jwa.SignatureAlgorithm
+	source    *Source
+}
 
-// NewRSAKeyGenerator is the preferred way to create a RSA key generator.
-func NewRSAKeyGenerator() *RSAKeyGenerator {
-	mrand.Seed(time.Now().UnixNano())
+// NewRSAKeyGenerator is the preferred way to create a RSA key generator for one of RS256, RS384, RS512,
+// PS256, PS384 or PS512.
+func NewRSAKeyGenerator(algorithm jwa.SignatureAlgorithm, source *Source) (*RSAKeyGenerator, error) {
+	if !rsaAlgorithms[algorithm] {
+		return nil, fmt.Errorf("%w: %v is not an RSA algorithm", ErrUnsupportedAlgorithm, algorithm)
+	}
 
-	return &RSAKeyGenerator{}
+	return &RSAKeyGenerator{
+		algorithm: algorithm,
+		source:    source,
+	}, nil
 }
 
 // GenerateKey generates a RSA signing key.
 func (k *RSAKeyGenerator) GenerateKey(length int) (*jwtmock.SigningKey, error) {
-	id := generateID(idLen)
-	key, err := rsa.GenerateKey(rand.Reader, length)
+	id, err := k.source.ID(idLen)
+	if err != nil {
+		return nil, err
+	}
+
+	var key *rsa.PrivateKey
+	if k.source.Deterministic() {
+		key, err = deterministicRSAKey(k.source, length)
+	} else {
+		key, err = rsa.GenerateKey(rand.Reader, length)
+	}
+
 	if err != nil {
 		return nil, err
 	}
@@ -37,17 +73,93 @@ func (k *RSAKeyGenerator) GenerateKey(length int) (*jwtmock.SigningKey, error) {
 	return &jwtmock.SigningKey{
 		ID:        id,
 		Key:       key,
-		Algorithm: jwa.RS256,
+		Algorithm: k.algorithm,
 		PublicKey: &key.PublicKey,
 	}, nil
 }
 
-// generateID generates an random string of the given length
-func generateID(n int) string {
-	b := make([]rune, n)
-	for i := range b {
-		// nolint:gosec // ignore weak rand warning
-		b[i] = idRunes[mrand.Intn(len(idRunes))]
+// deterministicRSAKey generates an RSA key which only depends on the bytes read from random - rsa.GenerateKey
+// always uses a secure source of randomness.
+func deterministicRSAKey(random io.Reader, bits int) (*rsa.PrivateKey, error) {
+	one := big.NewInt(1)
+	e := big.NewInt(rsaExponent)
+
+	for {
+		p, err := deterministicPrime(random, bits-bits/2)
+		if err != nil {
+			return nil, err
+		}
+
+		q, err := deterministicPrime(random, bits/2)
+		if err != nil {
+			return nil, err
+		}
+
+		n := new(big.Int).Mul(p, q)
+		if p.Cmp(q) == 0 || n.BitLen() != bits {
+			continue
+		}
+
+		// d is the inverse of e modulo lcm(p-1, q-1)
+		pMinus1 := new(big.Int).Sub(p, one)
+		qMinus1 := new(big.Int).Sub(q, one)
+		gcd := new(big.Int).GCD(nil, nil, pMinus1, qMinus1)
+		lcm := new(big.Int).Div(new(big.Int).Mul(pMinus1, qMinus1), gcd)
+
+		d := new(big.Int).ModInverse(e, lcm)
+		if d == nil {
+			continue
+		}
+
+		key := &rsa.PrivateKey{
+			PublicKey: rsa.PublicKey{N: n, E: rsaExponent},
+			D:         d,
+			Primes:    []*big.Int{p, q},
+		}
+
+		key.Precompute()
+		if err = key.Validate(); err != nil {
+			return nil, fmt.Errorf("validate: %w", err)
+		}
+
+		return key, nil
+	}
+}
+
+// deterministicPrime returns a prime of the given bit length which only depends on the bytes read from random.
+func deterministicPrime(random io.Reader, bits int) (*big.Int, error) {
+	if bits < 2 {
+		return nil, errors.New("prime size must be at least 2-bit")
+	}
+
+	b := make([]byte, (bits+7)/8)
+	topBits := uint(bits % 8)
+	if topBits == 0 {
+		topBits = 8
+	}
+
+	for {
+		if _, err := io.ReadFull(random, b); err != nil {
+			return nil, err
+		}
+
+		// keep the candidate within the bit length, with the two most significant bits set so that the product of
+		// two primes has the full bit length - and make it odd
+		b[0] &= uint8(int(1<<topBits) - 1)
+		if topBits >= 2 {
+			b[0] |= 3 << (topBits - 2)
+		} else {
+			b[0] |= 1
+			if len(b) > 1 {
+				b[1] |= 0x80
+			}
+		}
+
+		b[len(b)-1] |= 1
+
+		p := new(big.Int).SetBytes(b)
+		if p.ProbablyPrime(primeRounds) {
+			return p, nil
+		}
 	}
-	return string(b)
 }
@@ -1,58 +1,803 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"sync"
+	"time"
 
+	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/lestrrat-go/jwx/jwk"
 	"github.com/nayyara-cropsey/jwtmock"
 	"github.com/nayyara-cropsey/jwtmock/internal/jwks"
+	"github.com/nayyara-cropsey/jwtmock/log"
 )
 
-// KeyStore is used to keep state about current JWKS and signing key.
+var (
+	// ErrKeyNotFound means no signing key matches the requested key ID or algorithm.
+	ErrKeyNotFound = errors.New("signing key not found")
+
+	// ErrCurrentKey means the operation is not allowed on the current signing key.
+	ErrCurrentKey = errors.New("key is the current signing key")
+
+	// ErrDuplicateKeyID means another published key already uses the key ID.
+	ErrDuplicateKeyID = errors.New("key ID is already in use")
+
+	// ErrKeyNotStaged means the key is already published or retired.
+	ErrKeyNotStaged = errors.New("key is not staged")
+
+	// ErrKeyStaged means the operation is not allowed on a staged key - publish it first.
+	ErrKeyStaged = errors.New("key is staged")
+
+	// ErrUnsupportedRenewal means the certificate renewal behavior is not supported.
+	ErrUnsupportedRenewal = errors.New("unsupported certificate renewal")
+)
+
+const (
+	// certificates are checked for expiry at least this often when renewal is enabled
+	minCertCheckInterval = 100 * time.Millisecond
+	maxCertCheckInterval = time.Minute
+
+	// maxRetiredKeys is how many retired keys are kept for listing - older ones are dropped so that a store rotating
+	// keys for a long time does not grow without bound
+	maxRetiredKeys = 16
+)
+
+// storedKey is a signing key along with its published JWK (nil if it is not published).
+type storedKey struct {
+	signingKey *jwtmock.SigningKey
+	jwk        jwk.Key
+	status     jwtmock.KeyStatus
+
+	// retireAt is when a published-only key is retired - zero means it is published until explicitly retired.
+	retireAt time.Time
+
+	// publishAt is when a staged key is published - zero means it is staged until explicitly published.
+	publishAt time.Time
+
+	// externalCert means the key came with its own certificate chain, which is never renewed.
+	externalCert bool
+}
+
+// published returns true if the key is published in the JWKS.
+func (s *storedKey) published() bool {
+	return s.jwk != nil && s.status != jwtmock.KeyStatusRetired && s.status != jwtmock.KeyStatusStaged
+}
+
+// KeyStoreOption allows setting options on the key store.
+type KeyStoreOption func(*KeyStore)
+
+// WithKeyRetention option is used to set how long keys stay published after rotation by default.
+// Zero (the default) keeps rotated keys published until they are explicitly retired.
+func WithKeyRetention(retention time.Duration) KeyStoreOption {
+	return func(k *KeyStore) {
+		k.retention = retention
+	}
+}
+
+// WithCertificateRenewal option is used to rotate keys - or renew their certificates - once their certificate expires
+// within the given duration. Keys loaded with their own certificate chain are never renewed.
+func WithCertificateRenewal(renewal jwtmock.CertificateRenewal, renewBefore time.Duration) KeyStoreOption {
+	return func(k *KeyStore) {
+		k.renewal = renewal
+		k.renewBefore = renewBefore
+	}
+}
+
+// WithSource option is used to set the source of randomness for keys added for other algorithms and the clock for
+// certificate expiry - it should be the same source as used by the default key generator and certificate generator.
+func WithSource(source *Source) KeyStoreOption {
+	return func(k *KeyStore) {
+		k.source = source
+	}
+}
+
+// WithDecoyKeys option is used to add decoy entries of the given kinds to GetJWKS, ahead of the published keys.
+func WithDecoyKeys(decoys ...jwtmock.DecoyKey) KeyStoreOption {
+	return func(k *KeyStore) {
+		k.decoyKinds = append(k.decoyKinds, decoys...)
+	}
+}
+
+// WithSigningKeys option is used to start with existing signing keys (e.g. loaded from files) instead of generating
+// a new one. The first key is the current signing key.
+func WithSigningKeys(signingKeys ...*jwtmock.SigningKey) KeyStoreOption {
+	return func(k *KeyStore) {
+		k.initialKeys = append(k.initialKeys, signingKeys...)
+	}
+}
+
+// KeyStore is used to keep state about current JWKS and signing keys.
 type KeyStore struct {
-	generator *jwks.Generator
+	generator   *jwks.Generator
+	retention   time.Duration
+	initialKeys []*jwtmock.SigningKey
+	renewal     jwtmock.CertificateRenewal
+	renewBefore time.Duration
+	source      *Source
+	decoyKinds  []jwtmock.DecoyKey
+
+	// decoys are published ahead of the keys
+	decoys []*decoyKey
 
-	key    *jwtmock.SigningKey
-	jwkSet *jwk.Set
+	// keys are kept in the order they were added - current is the default signing key.
+	keys    []*storedKey
+	current *storedKey
 
 	m sync.Mutex
 }
 
 // NewKeyStore is the preferred way to instantiate a key store.
-func NewKeyStore(generator *jwks.Generator) (*KeyStore, error) {
+func NewKeyStore(generator *jwks.Generator, options ...KeyStoreOption) (*KeyStore, error) {
 	k := &KeyStore{
 		generator: generator,
+		source:    NewSource(),
 	}
 
-	if err := k.GenerateNew(); err != nil {
-		return nil, err
+	for _, option := range options {
+		option(k)
+	}
+
+	switch k.renewal {
+	case jwtmock.CertificateRenewalNone, jwtmock.CertificateRenewalRotate, jwtmock.CertificateRenewalRenew:
+	default:
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedRenewal, k.renewal)
+	}
+
+	for _, kind := range k.decoyKinds {
+		decoy, err := newDecoyKey(kind, k.source)
+		if err != nil {
+			return nil, err
+		}
+
+		k.decoys = append(k.decoys, decoy)
+	}
+
+	if len(k.initialKeys) == 0 {
+		if err := k.GenerateNew(); err != nil {
+			return nil, err
+		}
+
+		return k, nil
 	}
 
+	for _, signingKey := range k.initialKeys {
+		externalCert := len(signingKey.Certificates) > 0
+
+		key, err := generator.NewJWK(signingKey)
+		if err != nil {
+			return nil, fmt.Errorf("key %v: %w", signingKey.ID, err)
+		}
+
+		if err = k.add(&storedKey{
+			signingKey:   signingKey,
+			jwk:          key,
+			status:       jwtmock.KeyStatusActive,
+			externalCert: externalCert,
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	k.current = k.keys[0]
+
 	return k, nil
 }
 
-// GenerateNew generates a new pair of JWKS and signing key.
+// GenerateNew replaces all keys with a single new signing key.
 func (k *KeyStore) GenerateNew() error {
+	key, signingKey, err := k.generator.GenerateJWK()
+	if err != nil {
+		return err
+	}
+
+	k.m.Lock()
+	defer k.m.Unlock()
+
+	k.current = &storedKey{signingKey: signingKey, jwk: key, status: jwtmock.KeyStatusActive}
+	k.keys = []*storedKey{k.current}
+
+	return nil
+}
+
+// AddKey generates a new signing key for the given algorithm and publishes it alongside the existing keys.
+// An empty algorithm means the store's default algorithm. The current signing key is not changed.
+func (k *KeyStore) AddKey(alg jwa.SignatureAlgorithm) (*jwtmock.SigningKey, error) {
+	key, signingKey, err := k.generateJWK(alg)
+	if err != nil {
+		return nil, err
+	}
+
+	k.m.Lock()
+	defer k.m.Unlock()
+
+	if err = k.add(&storedKey{signingKey: signingKey, jwk: key, status: jwtmock.KeyStatusActive}); err != nil {
+		return nil, err
+	}
+
+	return signingKey, nil
+}
+
+// Rotate replaces the current signing key with a new one using the default retention.
+func (k *KeyStore) Rotate() (*jwtmock.SigningKey, error) {
+	return k.RotateWithRetention(k.retention)
+}
+
+// RotateWithRetention replaces the current signing key with a new one for the default algorithm. The previous key is
+// moved to published-only status, with a retirement time unless the retention is zero.
+func (k *KeyStore) RotateWithRetention(retention time.Duration) (*jwtmock.SigningKey, error) {
+	key, signingKey, err := k.generator.GenerateJWK()
+	if err != nil {
+		return nil, err
+	}
+
+	k.m.Lock()
+	defer k.m.Unlock()
+
+	current := &storedKey{signingKey: signingKey, jwk: key, status: jwtmock.KeyStatusActive}
+	if err = k.replace(k.current, current, retention); err != nil {
+		return nil, err
+	}
+
+	k.current = current
+
+	return signingKey, nil
+}
+
+// StageKey generates a new signing key for the given algorithm which signs JWTs but is withheld from the JWKS until
+// it is published - after the given delay, or explicitly if the delay is zero. The default algorithm is used if none
+// is given. The staged key replaces the current signing key (using the default retention) if current is true.
+func (k *KeyStore) StageKey(alg jwa.SignatureAlgorithm, publishAfter time.Duration,
+	current bool) (*jwtmock.SigningKey, error) {
+	key, signingKey, err := k.generateJWK(alg)
+	if err != nil {
+		return nil, err
+	}
+
+	k.m.Lock()
+	defer k.m.Unlock()
+
+	staged := &storedKey{signingKey: signingKey, jwk: key, status: jwtmock.KeyStatusStaged}
+	if publishAfter > 0 {
+		staged.publishAt = time.Now().Add(publishAfter)
+	}
+
+	if !current {
+		if err = k.add(staged); err != nil {
+			return nil, err
+		}
+
+		return signingKey, nil
+	}
+
+	if err = k.replace(k.current, staged, k.retention); err != nil {
+		return nil, err
+	}
+
+	k.current = staged
+
+	return signingKey, nil
+}
+
+// Publish adds the staged key with the given ID to the JWKS.
+func (k *KeyStore) Publish(kid string) error {
+	k.m.Lock()
+	defer k.m.Unlock()
+
+	k.updateExpired()
+
+	key, err := k.findKey(kid)
+	if err != nil {
+		return err
+	}
+
+	if key.status != jwtmock.KeyStatusStaged {
+		return fmt.Errorf("%w: kid %v is %v", ErrKeyNotStaged, kid, key.status)
+	}
+
+	key.status = jwtmock.KeyStatusActive
+
+	return nil
+}
+
+// StartRotation rotates the current signing key at the given interval in the background until the context is done.
+// Previous keys stay published for the default retention - or for one interval if there is none, since keys would
+// otherwise pile up in the JWKS. The returned channel is closed once rotation has stopped.
+func (k *KeyStore) StartRotation(ctx context.Context, interval time.Duration, logger *log.Logger) <-chan struct{} {
+	retention := k.retention
+	if retention <= 0 {
+		retention = interval
+	}
+
+	return runEvery(ctx, interval, func() {
+		signingKey, err := k.RotateWithRetention(retention)
+		if err != nil {
+			logger.Errorf("Failed scheduled key rotation: %v", err)
+			return
+		}
+
+		logger.Infof("Rotated signing key: kid=%v alg=%v", signingKey.ID, signingKey.Algorithm)
+	}, func() {
+		logger.Debug("Stopped scheduled key rotation")
+	})
+}
+
+// StartCertificateRenewal checks certificates for expiry in the background until the context is done - keys are
+// rotated or renewed as configured by WithCertificateRenewal. The returned channel is closed once checks have stopped,
+// it is nil if renewal is not enabled.
+func (k *KeyStore) StartCertificateRenewal(ctx context.Context, logger *log.Logger) <-chan struct{} {
+	if k.renewal == jwtmock.CertificateRenewalNone {
+		return nil
+	}
+
+	interval := k.renewBefore / 2
+	if interval < minCertCheckInterval {
+		interval = minCertCheckInterval
+	}
+
+	if interval > maxCertCheckInterval {
+		interval = maxCertCheckInterval
+	}
+
+	return runEvery(ctx, interval, func() {
+		k.renewCertificates(logger)
+	}, func() {
+		logger.Debug("Stopped certificate renewal")
+	})
+}
+
+// Retire stops the key with the given ID from signing JWTs and removes it from the JWKS.
+// The current signing key cannot be retired - rotate it first.
+func (k *KeyStore) Retire(kid string) error {
+	k.m.Lock()
+	defer k.m.Unlock()
+
+	key, err := k.findKey(kid)
+	if err != nil {
+		return err
+	}
+
+	if key == k.current {
+		return fmt.Errorf("%w: kid %v", ErrCurrentKey, kid)
+	}
+
+	key.status = jwtmock.KeyStatusRetired
+
+	return nil
+}
+
+// Deactivate moves the key with the given ID to published-only status, so JWTs it signed verify but it signs no more.
+// The current signing key cannot be deactivated - rotate it first. Staged keys cannot be deactivated either since they
+// were never published - publish or retire them instead.
+func (k *KeyStore) Deactivate(kid string) error {
 	k.m.Lock()
 	defer k.m.Unlock()
 
-	jwkSet, key, err := k.generator.GenerateJWKSet()
+	key, err := k.findKey(kid)
 	if err != nil {
 		return err
 	}
 
-	k.jwkSet = jwkSet
-	k.key = key
+	switch {
+	case key == k.current:
+		return fmt.Errorf("%w: kid %v", ErrCurrentKey, kid)
+	case key.status == jwtmock.KeyStatusRetired:
+		return fmt.Errorf("%w: kid %v is %v", ErrKeyNotFound, kid, key.status)
+	case key.status == jwtmock.KeyStatusStaged:
+		return fmt.Errorf("%w: kid %v", ErrKeyStaged, kid)
+	}
+
+	key.status = jwtmock.KeyStatusPublished
 
 	return nil
 }
 
-// GetJWKS returns the currently stored JWKS.
+// ListKeys returns all keys in the order they were added along with their status - retired keys are listed until
+// pruned.
+func (k *KeyStore) ListKeys() []jwtmock.KeyInfo {
+	k.m.Lock()
+	defer k.m.Unlock()
+
+	k.updateExpired()
+
+	keys := make([]jwtmock.KeyInfo, 0, len(k.keys))
+	for _, key := range k.keys {
+		keys = append(keys, jwtmock.KeyInfo{
+			ID:        key.signingKey.ID,
+			Algorithm: key.signingKey.Algorithm.String(),
+			Status:    key.status,
+			Current:   key == k.current,
+		})
+	}
+
+	return keys
+}
+
+// GetJWKS returns the currently published JWKS.
 func (k *KeyStore) GetJWKS() *jwk.Set {
-	return k.jwkSet
+	k.m.Lock()
+	defer k.m.Unlock()
+
+	k.updateExpired()
+
+	// the duplicate kid decoy copies the newest published kid - never a staged one, which would leak it early
+	var newest *jwtmock.SigningKey
+	for _, key := range k.keys {
+		if key.published() {
+			newest = key.signingKey
+		}
+	}
+
+	keySet := &jwk.Set{Keys: []jwk.Key{}}
+	for _, decoy := range k.decoys {
+		if newest == nil && decoy.kind == jwtmock.DecoyDuplicateKeyID {
+			continue
+		}
+
+		// decoy keys are generated ECDSA and Ed25519 keys, which always convert to JWKs
+		if key, err := decoy.decoyJWK(k.generator, newest); err == nil {
+			keySet.Keys = append(keySet.Keys, key)
+		}
+	}
+
+	for _, key := range k.keys {
+		if key.published() {
+			keySet.Keys = append(keySet.Keys, key.jwk)
+		}
+	}
+
+	return keySet
+}
+
+// FindKey returns the key with the given ID along with its JWK - unless it is retired. Unlike GetJWKS, staged keys are
+// returned, and keys kept out of the JWKS altogether (unpublished HMAC secrets) come with a nil JWK.
+func (k *KeyStore) FindKey(kid string) (jwk.Key, *jwtmock.SigningKey, error) {
+	k.m.Lock()
+	defer k.m.Unlock()
+
+	k.updateExpired()
+
+	key, err := k.findKey(kid)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if key.status == jwtmock.KeyStatusRetired {
+		return nil, nil, fmt.Errorf("%w: kid %v is %v", ErrKeyNotFound, kid, key.status)
+	}
+
+	return key.jwk, key.signingKey, nil
 }
 
-// GetSigningKey returns the currently stored signing key.
+// PublishedKeys returns the signing keys currently published in the JWKS - in the same order and without decoys.
+func (k *KeyStore) PublishedKeys() []*jwtmock.SigningKey {
+	k.m.Lock()
+	defer k.m.Unlock()
+
+	k.updateExpired()
+
+	var keys []*jwtmock.SigningKey
+	for _, key := range k.keys {
+		if key.published() {
+			keys = append(keys, key.signingKey)
+		}
+	}
+
+	return keys
+}
+
+// GetSigningKey returns the current (default) signing key.
 func (k *KeyStore) GetSigningKey() *jwtmock.SigningKey {
-	return k.key
+	k.m.Lock()
+	defer k.m.Unlock()
+
+	return k.current.signingKey
+}
+
+// FindSigningKey returns the active signing key with the given key ID, or the first active key using the given
+// algorithm (preferring the current key) if no key ID is given. The current key is returned if neither is given.
+func (k *KeyStore) FindSigningKey(kid string, alg jwa.SignatureAlgorithm) (*jwtmock.SigningKey, error) {
+	k.m.Lock()
+	defer k.m.Unlock()
+
+	switch {
+	case kid != "":
+		key, err := k.findKey(kid)
+		if err != nil {
+			return nil, err
+		}
+
+		if !signs(key.status) {
+			return nil, fmt.Errorf("%w: kid %v is %v", ErrKeyNotFound, kid, key.status)
+		}
+
+		return key.signingKey, nil
+	case alg != "":
+		if k.current.signingKey.Algorithm == alg {
+			return k.current.signingKey, nil
+		}
+
+		for _, key := range k.keys {
+			if signs(key.status) && key.signingKey.Algorithm == alg {
+				return key.signingKey, nil
+			}
+		}
+
+		return nil, fmt.Errorf("%w: alg %v", ErrKeyNotFound, alg)
+	default:
+		return k.current.signingKey, nil
+	}
+}
+
+// renewCertificates rotates or renews keys whose certificates expire within the renewal window.
+func (k *KeyStore) renewCertificates(logger *log.Logger) {
+	for _, key := range k.expiringKeys() {
+		kid := key.signingKey.ID
+
+		switch {
+		case k.renewal == jwtmock.CertificateRenewalRenew:
+			signingKey, err := k.renewKey(key)
+			if err != nil {
+				logger.Errorf("Failed to renew certificate: kid=%v: %v", kid, err)
+				continue
+			}
+
+			logger.Infof("Renewed certificate: kid=%v not-after=%v", kid, signingKey.Certificates[0].NotAfter)
+		case k.retireKey(key):
+			// keys that no longer sign JWTs are not replaced - they are retired along with their certificate
+			logger.Infof("Retired key with expiring certificate: kid=%v", kid)
+		default:
+			signingKey, err := k.rotateKey(key)
+			if err != nil {
+				logger.Errorf("Failed to rotate key with expiring certificate: kid=%v: %v", kid, err)
+				continue
+			}
+
+			logger.Infof("Rotated key with expiring certificate: kid=%v new-kid=%v", kid, signingKey.ID)
+		}
+	}
+}
+
+// expiringKeys returns keys with generated certificates that expire within the renewal window.
+func (k *KeyStore) expiringKeys() []*storedKey {
+	k.m.Lock()
+	defer k.m.Unlock()
+
+	k.updateExpired()
+
+	// certificates are issued with the source clock so they expire by it too
+	var expiring []*storedKey
+	now := k.source.Now()
+	for _, key := range k.keys {
+		if key.status == jwtmock.KeyStatusRetired || key.externalCert || len(key.signingKey.Certificates) == 0 {
+			continue
+		}
+
+		// staged keys are not rotated since that would publish them
+		if key.status == jwtmock.KeyStatusStaged && k.renewal != jwtmock.CertificateRenewalRenew {
+			continue
+		}
+
+		if !now.Before(key.signingKey.Certificates[0].NotAfter.Add(-k.renewBefore)) {
+			expiring = append(expiring, key)
+		}
+	}
+
+	return expiring
+}
+
+// retireKey retires the given key unless it signs JWTs - reporting whether it is retired.
+func (k *KeyStore) retireKey(old *storedKey) bool {
+	k.m.Lock()
+	defer k.m.Unlock()
+
+	if signs(old.status) {
+		return false
+	}
+
+	old.status = jwtmock.KeyStatusRetired
+
+	return true
+}
+
+// rotateKey replaces the given key with a new key for the same algorithm - using the default retention. The key must
+// still sign JWTs once the new key is generated.
+func (k *KeyStore) rotateKey(old *storedKey) (*jwtmock.SigningKey, error) {
+	key, signingKey, err := k.generateJWK(old.signingKey.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	k.m.Lock()
+	defer k.m.Unlock()
+
+	// the key may have been deactivated, rotated or retired while the new key was generated
+	if !signs(old.status) {
+		return nil, fmt.Errorf("%w: kid %v is %v", ErrKeyNotFound, old.signingKey.ID, old.status)
+	}
+
+	current := &storedKey{signingKey: signingKey, jwk: key, status: jwtmock.KeyStatusActive}
+	if err = k.replace(old, current, k.retention); err != nil {
+		return nil, err
+	}
+
+	if old == k.current {
+		k.current = current
+	}
+
+	return signingKey, nil
+}
+
+// renewKey issues a new certificate for the given key.
+func (k *KeyStore) renewKey(old *storedKey) (*jwtmock.SigningKey, error) {
+	signingKey := &jwtmock.SigningKey{
+		ID:        old.signingKey.ID,
+		Key:       old.signingKey.Key,
+		Algorithm: old.signingKey.Algorithm,
+		PublicKey: old.signingKey.PublicKey,
+	}
+
+	key, err := k.generator.NewJWK(signingKey)
+	if err != nil {
+		return nil, err
+	}
+
+	k.m.Lock()
+	defer k.m.Unlock()
+
+	old.signingKey = signingKey
+	old.jwk = key
+
+	return signingKey, nil
+}
+
+// replace stops the old key from signing JWTs - it stays published for the given retention - and adds the new key.
+// A staged old key, or one whose key ID is reused by the new key (e.g. a fixed key ID), is retired instead.
+// The caller must hold the lock.
+func (k *KeyStore) replace(old, key *storedKey, retention time.Duration) error {
+	if old.signingKey.ID == key.signingKey.ID {
+		status := old.status
+		old.status = jwtmock.KeyStatusRetired
+
+		if err := k.add(key); err != nil {
+			old.status = status
+			return err
+		}
+
+		return nil
+	}
+
+	if err := k.add(key); err != nil {
+		return err
+	}
+
+	if old.status == jwtmock.KeyStatusStaged {
+		old.status = jwtmock.KeyStatusRetired
+		return nil
+	}
+
+	old.status = jwtmock.KeyStatusPublished
+	if retention > 0 {
+		old.retireAt = time.Now().Add(retention)
+	}
+
+	return nil
+}
+
+// add adds a new key unless its ID is used by a key that is not retired, pruning old retired keys - the caller must
+// hold the lock.
+func (k *KeyStore) add(key *storedKey) error {
+	kid := key.signingKey.ID
+	if existing, err := k.findKey(kid); err == nil && existing.status != jwtmock.KeyStatusRetired {
+		return fmt.Errorf("%w: kid %v", ErrDuplicateKeyID, kid)
+	}
+
+	k.keys = append(k.keys, key)
+	k.pruneRetired()
+
+	return nil
+}
+
+// pruneRetired drops all but the most recent retired keys - the caller must hold the lock.
+func (k *KeyStore) pruneRetired() {
+	retired := 0
+	for _, key := range k.keys {
+		if key.status == jwtmock.KeyStatusRetired {
+			retired++
+		}
+	}
+
+	if retired <= maxRetiredKeys {
+		return
+	}
+
+	keys := k.keys[:0]
+	for _, key := range k.keys {
+		if key.status == jwtmock.KeyStatusRetired && retired > maxRetiredKeys {
+			retired--
+			continue
+		}
+
+		keys = append(keys, key)
+	}
+
+	// clear the tail so that dropped keys can be garbage collected
+	for i := len(keys); i < len(k.keys); i++ {
+		k.keys[i] = nil
+	}
+
+	k.keys = keys
+}
+
+// findKey returns the stored key with the given ID - the most recent if the ID has been reused by a key after
+// retirement. The caller must hold the lock.
+func (k *KeyStore) findKey(kid string) (*storedKey, error) {
+	for i := len(k.keys) - 1; i >= 0; i-- {
+		if k.keys[i].signingKey.ID == kid {
+			return k.keys[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: kid %v", ErrKeyNotFound, kid)
+}
+
+// updateExpired retires published-only keys whose retention has passed, publishes staged keys whose delay has passed
+// and prunes old retired keys - the caller must hold the lock.
+func (k *KeyStore) updateExpired() {
+	now := time.Now()
+	for _, key := range k.keys {
+		switch {
+		case key.status == jwtmock.KeyStatusPublished && !key.retireAt.IsZero() && !now.Before(key.retireAt):
+			key.status = jwtmock.KeyStatusRetired
+		case key.status == jwtmock.KeyStatusStaged && !key.publishAt.IsZero() && !now.Before(key.publishAt):
+			key.status = jwtmock.KeyStatusActive
+		}
+	}
+
+	k.pruneRetired()
+}
+
+// signs returns true if keys with the given status sign JWTs.
+func signs(status jwtmock.KeyStatus) bool {
+	return status == jwtmock.KeyStatusActive || status == jwtmock.KeyStatusStaged
+}
+
+// generateJWK generates a signing key and JWK for the given algorithm - or the default algorithm if none is given.
+func (k *KeyStore) generateJWK(alg jwa.SignatureAlgorithm) (jwk.Key, *jwtmock.SigningKey, error) {
+	if alg == "" {
+		return k.generator.GenerateJWK()
+	}
+
+	keyGen, err := NewKeyGenerator(alg, k.source)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return k.generator.GenerateJWKWith(keyGen)
+}
+
+// runEvery calls fn at the given interval in the background until the context is done - stopped is called once
+// before the returned channel is closed.
+func runEvery(ctx context.Context, interval time.Duration, fn, stopped func()) <-chan struct{} {
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				stopped()
+				return
+			case <-ticker.C:
+				fn()
+			}
+		}
+	}()
+
+	return done
 }
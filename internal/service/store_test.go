@@ -0,0 +1,277 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/nayyara-cropsey/jwtmock"
+	"github.com/nayyara-cropsey/jwtmock/internal/jwks"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestKeyStore(t *testing.T, alg jwa.SignatureAlgorithm, source *Source, strategy jwtmock.KeyIDStrategy,
+	fixedID string) *KeyStore {
+	t.Helper()
+
+	certGenerator, err := NewCertificateGenerator(time.Hour, source)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	keyGenerator, err := NewKeyGenerator(alg, source)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	keyID, err := NewKeyIDFunc(strategy, fixedID, "")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	store, err := NewKeyStore(jwks.NewGenerator(certGenerator, keyGenerator, 1024, jwks.WithKeyIDFunc(keyID)),
+		WithSource(source))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	return store
+}
+
+func TestKeyStore_KeyStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy jwtmock.KeyIDStrategy
+
+		// change changes the key store and returns the ID of the key to check
+		change func(t *testing.T, store *KeyStore) string
+
+		status    jwtmock.KeyStatus
+		published bool
+		signs     bool
+		keys      int
+	}{
+		{
+			name: "staged",
+			change: func(t *testing.T, store *KeyStore) string {
+				signingKey, err := store.StageKey("", 0, false)
+				assert.NoError(t, err)
+
+				return signingKey.ID
+			},
+			status: jwtmock.KeyStatusStaged,
+			signs:  true,
+			keys:   2,
+		},
+		{
+			name: "staged to active",
+			change: func(t *testing.T, store *KeyStore) string {
+				signingKey, err := store.StageKey("", 0, false)
+				assert.NoError(t, err)
+				assert.NoError(t, store.Publish(signingKey.ID))
+
+				return signingKey.ID
+			},
+			status:    jwtmock.KeyStatusActive,
+			published: true,
+			signs:     true,
+			keys:      2,
+		},
+		{
+			name: "staged to active after delay",
+			change: func(t *testing.T, store *KeyStore) string {
+				signingKey, err := store.StageKey("", 10*time.Millisecond, false)
+				assert.NoError(t, err)
+
+				time.Sleep(20 * time.Millisecond)
+
+				return signingKey.ID
+			},
+			status:    jwtmock.KeyStatusActive,
+			published: true,
+			signs:     true,
+			keys:      2,
+		},
+		{
+			name: "staged current to retired on rotation",
+			change: func(t *testing.T, store *KeyStore) string {
+				signingKey, err := store.StageKey("", 0, true)
+				assert.NoError(t, err)
+
+				_, err = store.RotateWithRetention(0)
+				assert.NoError(t, err)
+
+				return signingKey.ID
+			},
+			status: jwtmock.KeyStatusRetired,
+			keys:   3,
+		},
+		{
+			name: "active to published",
+			change: func(t *testing.T, store *KeyStore) string {
+				kid := store.GetSigningKey().ID
+
+				_, err := store.RotateWithRetention(0)
+				assert.NoError(t, err)
+
+				return kid
+			},
+			status:    jwtmock.KeyStatusPublished,
+			published: true,
+			keys:      2,
+		},
+		{
+			name: "published to retired",
+			change: func(t *testing.T, store *KeyStore) string {
+				kid := store.GetSigningKey().ID
+
+				_, err := store.RotateWithRetention(10 * time.Millisecond)
+				assert.NoError(t, err)
+
+				time.Sleep(20 * time.Millisecond)
+
+				return kid
+			},
+			status: jwtmock.KeyStatusRetired,
+			keys:   2,
+		},
+		{
+			name:     "rename on a reused kid",
+			strategy: jwtmock.KeyIDFixed,
+			change: func(t *testing.T, store *KeyStore) string {
+				signingKey, err := store.RotateWithRetention(time.Hour)
+				assert.NoError(t, err)
+
+				return signingKey.ID
+			},
+			status:    jwtmock.KeyStatusActive,
+			published: true,
+			signs:     true,
+			keys:      2,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			store := newTestKeyStore(t, jwa.ES256, NewSource(), tt.strategy, "fixed")
+
+			kid := tt.change(t, store)
+
+			keys := store.ListKeys()
+			assert.Len(t, keys, tt.keys)
+
+			// the most recent key with the ID is the one checked
+			statuses := map[string]jwtmock.KeyStatus{}
+			for _, key := range keys {
+				statuses[key.ID] = key.Status
+			}
+
+			assert.Equal(t, tt.status, statuses[kid])
+
+			published := false
+			for _, key := range store.GetJWKS().Keys {
+				published = published || key.KeyID() == kid
+			}
+
+			assert.Equal(t, tt.published, published)
+
+			_, err := store.FindSigningKey(kid, "")
+			assert.Equal(t, tt.signs, err == nil)
+		})
+	}
+}
+
+func TestKeyStore_ReusedKeyIDRetiresPrevious(t *testing.T) {
+	store := newTestKeyStore(t, jwa.ES256, NewSource(), jwtmock.KeyIDFixed, "fixed")
+
+	previousKey := store.GetSigningKey()
+
+	signingKey, err := store.RotateWithRetention(time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, previousKey.ID, signingKey.ID)
+
+	// the previous key can't stay published under the same ID, so only the new key is in the JWKS
+	keys := store.ListKeys()
+	if assert.Len(t, keys, 2) {
+		assert.Equal(t, jwtmock.KeyStatusRetired, keys[0].Status)
+		assert.Equal(t, jwtmock.KeyStatusActive, keys[1].Status)
+	}
+
+	keySet := store.GetJWKS()
+	assert.Len(t, keySet.Keys, 1)
+
+	_, found, err := store.FindKey(signingKey.ID)
+	if assert.NoError(t, err) {
+		assert.Equal(t, signingKey, found)
+	}
+}
+
+func TestKeyStore_DeterministicKeys(t *testing.T) {
+	fixedTime := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		alg  jwa.SignatureAlgorithm
+		seed int64
+		same bool
+	}{
+		{alg: jwa.RS256, seed: 42, same: true},
+		{alg: jwa.ES256, seed: 42, same: true},
+		{alg: jwa.EdDSA, seed: 42, same: true},
+		{alg: jwa.ES256, seed: 7},
+		{alg: jwa.EdDSA, seed: 7},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(fmt.Sprintf("%v seed %v", tt.alg, tt.seed), func(t *testing.T) {
+			stores := []*KeyStore{
+				newTestKeyStore(t, tt.alg, NewSeededSource(42, fixedTime), "", ""),
+				newTestKeyStore(t, tt.alg, NewSeededSource(tt.seed, fixedTime), "", ""),
+			}
+
+			// keys generated on rotation are derived from the seed as well
+			for i := 0; i < 2; i++ {
+				var keySets []string
+				for _, store := range stores {
+					keySet, err := json.Marshal(store.GetJWKS())
+					assert.NoError(t, err)
+					keySets = append(keySets, string(keySet))
+
+					_, err = store.Rotate()
+					assert.NoError(t, err)
+				}
+
+				assert.Equal(t, tt.same, keySets[0] == keySets[1])
+			}
+		})
+	}
+}
+
+func TestKeyStore_RenewalRechecksStatus(t *testing.T) {
+	store := newTestKeyStore(t, jwa.ES256, NewSource(), "", "")
+
+	signingKey, err := store.AddKey("")
+	assert.NoError(t, err)
+
+	store.m.Lock()
+	key, err := store.findKey(signingKey.ID)
+	store.m.Unlock()
+	assert.NoError(t, err)
+
+	// a key retired after it was found to expire is neither replaced nor brought back
+	assert.NoError(t, store.Retire(signingKey.ID))
+
+	_, err = store.rotateKey(key)
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+	assert.True(t, store.retireKey(key))
+
+	keys := store.ListKeys()
+	if assert.Len(t, keys, 2) {
+		assert.Equal(t, jwtmock.KeyStatusRetired, keys[1].Status)
+	}
+}
@@ -0,0 +1,120 @@
+package jwtmocktest
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha1" // nolint:gosec // x5t is defined as a SHA-1 thumbprint
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/lestrrat-go/jwx/jwk"
+)
+
+// ErrNonConformingKeySet means a JWKS does not conform to RFC 7517.
+var ErrNonConformingKeySet = errors.New("non-conforming JWKS")
+
+// ValidateKeySet checks that key IDs in the JWKS are unique and that any X.509 certificate chain and thumbprints are
+// consistent with each other and the key - i.e. the checks strict consumers make before trusting a JWKS. The "kid"
+// is optional (RFC 7517 section 4.5), so keys without one are only checked for consistency.
+func ValidateKeySet(keySet *jwk.Set) error {
+	if keySet == nil || len(keySet.Keys) == 0 {
+		return fmt.Errorf("%w: no keys", ErrNonConformingKeySet)
+	}
+
+	kids := make(map[string]bool, len(keySet.Keys))
+	for i, key := range keySet.Keys {
+		kid := key.KeyID()
+		if kid == "" {
+			if err := validateKey(key); err != nil {
+				return fmt.Errorf("%w: key %v: %v", ErrNonConformingKeySet, i, err)
+			}
+
+			continue
+		}
+
+		if kids[kid] {
+			return fmt.Errorf("%w: duplicate kid %v", ErrNonConformingKeySet, kid)
+		}
+
+		kids[kid] = true
+
+		if err := validateKey(key); err != nil {
+			return fmt.Errorf("%w: kid %v: %v", ErrNonConformingKeySet, kid, err)
+		}
+	}
+
+	return nil
+}
+
+// validateKey checks the X.509 parameters of a single key.
+func validateKey(key jwk.Key) error {
+	chain := key.X509CertChain()
+	if len(chain) == 0 {
+		if key.X509CertThumbprint() != "" || key.X509CertThumbprintS256() != "" {
+			return errors.New("x5t without x5c")
+		}
+
+		return nil
+	}
+
+	// each certificate in the chain must certify the previous one (RFC 7517 section 4.7)
+	for i := 1; i < len(chain); i++ {
+		if err := chain[i-1].CheckSignatureFrom(chain[i]); err != nil {
+			return fmt.Errorf("x5c certificate %v is not signed by the next certificate: %v", i-1, err)
+		}
+	}
+
+	leaf := chain[0]
+
+	// nolint:gosec // ignore weak cryptographic algorithm warning
+	x5t := sha1.Sum(leaf.Raw)
+	if err := validateThumbprint("x5t", key.X509CertThumbprint(), x5t[:]); err != nil {
+		return err
+	}
+
+	x5tS256 := sha256.Sum256(leaf.Raw)
+	if err := validateThumbprint("x5t#S256", key.X509CertThumbprintS256(), x5tS256[:]); err != nil {
+		return err
+	}
+
+	certKey, err := jwk.New(leaf.PublicKey)
+	if err != nil {
+		return fmt.Errorf("x5c public key: %v", err)
+	}
+
+	certThumbprint, err := certKey.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return fmt.Errorf("x5c thumbprint: %v", err)
+	}
+
+	keyThumbprint, err := key.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return fmt.Errorf("key thumbprint: %v", err)
+	}
+
+	if !bytes.Equal(certThumbprint, keyThumbprint) {
+		return errors.New("x5c certificate does not match key")
+	}
+
+	return nil
+}
+
+// validateThumbprint checks that an optional thumbprint is the base64url encoding of the expected digest.
+func validateThumbprint(name, thumbprint string, expected []byte) error {
+	if thumbprint == "" {
+		return nil
+	}
+
+	digest, err := base64.RawURLEncoding.DecodeString(thumbprint)
+	if err != nil {
+		return fmt.Errorf("%v is not base64url encoded: %v", name, err)
+	}
+
+	if !bytes.Equal(digest, expected) {
+		return fmt.Errorf("%v does not match x5c", name)
+	}
+
+	return nil
+}
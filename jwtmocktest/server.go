@@ -1,10 +1,15 @@
 package jwtmocktest
 
 import (
+	"context"
+	"crypto/x509"
+	"errors"
 	"fmt"
 
 	"net/http/httptest"
+	"net/url"
 
+	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/nayyara-cropsey/jwtmock"
 	"github.com/nayyara-cropsey/jwtmock/internal/handlers"
 	"github.com/nayyara-cropsey/jwtmock/internal/jwks"
@@ -18,8 +23,293 @@ var (
 	// reasonable test defaults
 	defaultCertLen = 24 * time.Hour
 	defaultKeyLen  = 1024
+	defaultAlg     = jwa.RS256
 )
 
+var (
+	// ErrNoSharedSecret means the server is not signing JWTs with a shared secret.
+	ErrNoSharedSecret = errors.New("signing key is not a shared secret")
+
+	// ErrExportDisabled means private key export was not enabled for the server.
+	ErrExportDisabled = errors.New("private key export is disabled")
+)
+
+// serverConfig holds settings used to build a Server.
+type serverConfig struct {
+	algorithm        jwa.SignatureAlgorithm
+	hmacSecret       []byte
+	publishSymmetric bool
+	additionalKeys   []jwa.SignatureAlgorithm
+	rotationInterval time.Duration
+	keyRetention     time.Duration
+	keyPEMs          []pemKey
+	exportKeys       bool
+	certLifetime     time.Duration
+	renewal          jwtmock.CertificateRenewal
+	renewBefore      time.Duration
+	seed             *int64
+	fixedTime        time.Time
+	keyIDStrategy    jwtmock.KeyIDStrategy
+	fixedKeyID       string
+	keyIDPrefix      string
+	keyLength        int
+	keyPool          bool
+	sharedKey        bool
+	decoys           []jwtmock.DecoyKey
+	claimDefaults    jwtmock.ClaimDefaults
+}
+
+// pemKey is a PEM-encoded private key and optional certificate chain.
+type pemKey struct {
+	key  []byte
+	cert []byte
+}
+
+// ServerOption allows setting options on the server.
+type ServerOption func(*serverConfig)
+
+// WithSigningAlgorithm option is used to set the algorithm used to sign JWTs (RS256 by default).
+func WithSigningAlgorithm(alg jwa.SignatureAlgorithm) ServerOption {
+	return func(c *serverConfig) {
+		c.algorithm = alg
+	}
+}
+
+// WithHMACSecret option is used to set the shared secret used with an HMAC signing algorithm (HS256, HS384 or HS512).
+// A random secret is generated if this option is not set.
+func WithHMACSecret(secret []byte) ServerOption {
+	return func(c *serverConfig) {
+		c.hmacSecret = secret
+	}
+}
+
+// WithSymmetricKeyPublishing option is used to publish the HMAC shared secret in the JWKS as an "oct" key.
+// This is never the case for a real authorization server and is only useful for negative testing.
+func WithSymmetricKeyPublishing() ServerOption {
+	return func(c *serverConfig) {
+		c.publishSymmetric = true
+	}
+}
+
+// WithAdditionalKeys option is used to publish keys for the given algorithms alongside the main signing key.
+// Use token options to sign JWTs with these keys.
+func WithAdditionalKeys(algs ...jwa.SignatureAlgorithm) ServerOption {
+	return func(c *serverConfig) {
+		c.additionalKeys = append(c.additionalKeys, algs...)
+	}
+}
+
+// WithDecoyKeys option is used to publish decoy entries in the JWKS ahead of the signing keys - e.g. encryption keys,
+// keys of an unknown type or a key reusing the key ID of the signing key. Consumers must ignore them.
+func WithDecoyKeys(decoys ...jwtmock.DecoyKey) ServerOption {
+	return func(c *serverConfig) {
+		c.decoys = append(c.decoys, decoys...)
+	}
+}
+
+// WithKeyRotation option is used to rotate the signing key at the given interval until the server is closed.
+// Previous keys stay published for the given retention - or for one interval if the retention is zero.
+func WithKeyRotation(interval, retention time.Duration) ServerOption {
+	return func(c *serverConfig) {
+		c.rotationInterval = interval
+		c.keyRetention = retention
+	}
+}
+
+// WithSigningKeyPEM option is used to sign JWTs with an existing PEM-encoded RSA, ECDSA or Ed25519 private key
+// instead of a generated one. The PEM-encoded certificate chain (leaf certificate first) is optional and must
+// match the key. Use this option multiple times to load several keys - the first is the current signing key.
+func WithSigningKeyPEM(keyPEM, certPEM []byte) ServerOption {
+	return func(c *serverConfig) {
+		c.keyPEMs = append(c.keyPEMs, pemKey{key: keyPEM, cert: certPEM})
+	}
+}
+
+// WithPrivateKeyExport option is used to allow private signing keys to be exported, using ExportSigningKey
+// or the /jwtmock/signing-key endpoint, and to serve the HMAC shared secret from the /jwtmock/secret endpoint.
+func WithPrivateKeyExport() ServerOption {
+	return func(c *serverConfig) {
+		c.exportKeys = true
+	}
+}
+
+// WithCertificateLifetime option is used to set how long certificates of generated keys are valid (24 hours by
+// default).
+func WithCertificateLifetime(lifetime time.Duration) ServerOption {
+	return func(c *serverConfig) {
+		c.certLifetime = lifetime
+	}
+}
+
+// WithCertificateRenewal option is used to rotate keys, or renew their certificates, once their certificate expires
+// within the given duration. By default nothing is done and expired certificates stay published.
+func WithCertificateRenewal(renewal jwtmock.CertificateRenewal, renewBefore time.Duration) ServerOption {
+	return func(c *serverConfig) {
+		c.renewal = renewal
+		c.renewBefore = renewBefore
+	}
+}
+
+// WithSeed option is used to derive keys, key IDs and certificate serial numbers from the given seed instead of a
+// secure source of randomness. Servers with the same seed and options publish the same JWKS and sign identical JWTs
+// for the same claims with RS*, EdDSA and HS* algorithms (PS* and ES* signatures are always randomized).
+// The root CA key is an Ed25519 key in this mode. Use WithFixedTime for stable certificate validity.
+func WithSeed(seed int64) ServerOption {
+	return func(c *serverConfig) {
+		c.seed = &seed
+	}
+}
+
+// WithFixedTime option is used to fix the clock used for certificate validity, default claims and claim validation
+// when used along with WithSeed.
+func WithFixedTime(fixedTime time.Time) ServerOption {
+	return func(c *serverConfig) {
+		c.fixedTime = fixedTime
+	}
+}
+
+// WithKeyIDStrategy option is used to choose how key IDs of generated keys are chosen - random by default.
+// Use jwtmock.KeyIDThumbprint for RFC 7638 thumbprints as used by several providers.
+func WithKeyIDStrategy(strategy jwtmock.KeyIDStrategy) ServerOption {
+	return func(c *serverConfig) {
+		c.keyIDStrategy = strategy
+	}
+}
+
+// WithFixedKeyID option is used to give the generated signing key a fixed key ID.
+// Only one key can use the ID at a time so this is meant for servers with a single key.
+func WithFixedKeyID(kid string) ServerOption {
+	return func(c *serverConfig) {
+		c.keyIDStrategy = jwtmock.KeyIDFixed
+		c.fixedKeyID = kid
+	}
+}
+
+// WithKeyIDPrefix option is used to prepend a prefix to the key IDs of generated keys.
+func WithKeyIDPrefix(prefix string) ServerOption {
+	return func(c *serverConfig) {
+		c.keyIDPrefix = prefix
+	}
+}
+
+// WithKeyLength option is used to set the length of generated RSA keys (1024 bits by default).
+func WithKeyLength(bits int) ServerOption {
+	return func(c *serverConfig) {
+		c.keyLength = bits
+	}
+}
+
+// WithKeyPool option is used to take keys from a process-wide pool of keys generated ahead of time in the background
+// instead of on demand. The pool is warmed by the first server created with this option (for its algorithm and key
+// length) - it pays off when tests spend time between creating servers, and has no effect with WithSeed.
+func WithKeyPool() ServerOption {
+	return func(c *serverConfig) {
+		c.keyPool = true
+	}
+}
+
+// WithSharedKey option is used to start with a signing key shared by all servers created with this option (and the
+// same algorithm and key length) in the process - this is the fastest way to create many servers. Each server still
+// has its own certificates and applies its key ID options to the key. The option has no effect with WithSeed,
+// WithHMACSecret or WithSigningKeyPEM.
+func WithSharedKey() ServerOption {
+	return func(c *serverConfig) {
+		c.sharedKey = true
+	}
+}
+
+// WithTokenTTL option is used to set the lifetime of JWTs generated without an "exp" claim (one hour by default) - no
+// "exp" claim is set if zero.
+func WithTokenTTL(ttl time.Duration) ServerOption {
+	return func(c *serverConfig) {
+		c.claimDefaults.TTL = ttl
+	}
+}
+
+// WithIssuer option is used to set the "iss" claim of JWTs generated without one.
+func WithIssuer(issuer string) ServerOption {
+	return func(c *serverConfig) {
+		c.claimDefaults.Issuer = issuer
+	}
+}
+
+// WithAudience option is used to set the "aud" claim of JWTs generated without one.
+func WithAudience(audience ...string) ServerOption {
+	return func(c *serverConfig) {
+		c.claimDefaults.Audience = append(c.claimDefaults.Audience, audience...)
+	}
+}
+
+// handlerOptions returns options for optional routes and generated JWTs.
+func (c *serverConfig) handlerOptions() []handlers.HandlerOption {
+	options := []handlers.HandlerOption{handlers.WithClaimDefaults(c.claimDefaults)}
+	if c.exportKeys {
+		options = append(options, handlers.WithPrivateKeyExport(service.ExportSigningKey))
+	}
+
+	return options
+}
+
+// keyStoreOptions returns options for the key store - loading any configured keys. The shared key (if any) gets its
+// key ID from the given function like generated keys.
+func (c *serverConfig) keyStoreOptions(source *service.Source, keyID jwks.KeyIDFunc) ([]service.KeyStoreOption,
+	error) {
+	options := []service.KeyStoreOption{
+		service.WithKeyRetention(c.keyRetention),
+		service.WithCertificateRenewal(c.renewal, c.renewBefore),
+		service.WithSource(source),
+		service.WithDecoyKeys(c.decoys...),
+	}
+	for _, p := range c.keyPEMs {
+		signingKey, err := service.LoadSigningKey(p.key, p.cert, "", "")
+		if err != nil {
+			return nil, fmt.Errorf("load key: %w", err)
+		}
+
+		options = append(options, service.WithSigningKeys(signingKey))
+	}
+
+	if c.sharedKey && len(c.keyPEMs) == 0 && c.seed == nil && len(c.hmacSecret) == 0 {
+		signingKey, err := service.DefaultKeyPool.SharedKey(c.algorithm, c.keyLength)
+		if err != nil {
+			return nil, fmt.Errorf("shared key: %w", err)
+		}
+
+		if signingKey.ID, err = keyID(signingKey); err != nil {
+			return nil, fmt.Errorf("shared key ID: %w", err)
+		}
+
+		options = append(options, service.WithSigningKeys(signingKey))
+	}
+
+	return options, nil
+}
+
+// keyGenerator creates the key generator of the server - an HMAC secret takes precedence over the key pool.
+func (c *serverConfig) keyGenerator(source *service.Source) (service.KeyGenerator, error) {
+	switch {
+	case len(c.hmacSecret) > 0:
+		return service.NewHMACKeyGenerator(c.algorithm, c.hmacSecret, source)
+	case c.keyPool && !source.Deterministic():
+		if err := service.DefaultKeyPool.Warm(c.algorithm, c.keyLength); err != nil {
+			return nil, err
+		}
+
+		return service.DefaultKeyPool.KeyGenerator(c.algorithm)
+	default:
+		return service.NewKeyGenerator(c.algorithm, source)
+	}
+}
+
+// source creates the source of randomness - which is deterministic if a seed is configured.
+func (c *serverConfig) source() *service.Source {
+	if c.seed == nil {
+		return service.NewSource()
+	}
+
+	return service.NewSeededSource(*c.seed, c.fixedTime)
+}
+
 // A Server is an HTTP server listening on a system-chosen port on the
 // local loopback interface, for use in end-to-end HTTP tests.
 type Server struct {
@@ -27,37 +317,219 @@ type Server struct {
 
 	keystore    *service.KeyStore
 	clientsRepo *service.ClientRepo
+	rootCert    *x509.Certificate
+
+	// stopBackground stops scheduled key rotation and certificate renewal (if any) - the done channels are closed
+	// once they have stopped.
+	stopBackground context.CancelFunc
+	rotationDone   <-chan struct{}
+	renewalDone    <-chan struct{}
+
+	exportKeys    bool
+	claimDefaults jwtmock.ClaimDefaults
 }
 
-// NewServer starts and returns a new Server.
+// NewServer starts and returns a new Server configured with the given options.
 // The caller should call Close when finished, to shut it down.
-func NewServer() (*Server, error) {
-	certGenerator := service.NewCertificateGenerator(defaultCertLen)
-	keyGenerator := jwks.NewGenerator(certGenerator, service.NewRSAKeyGenerator(), defaultKeyLen)
-	keyStore, err := service.NewKeyStore(keyGenerator)
+func NewServer(options ...ServerOption) (*Server, error) {
+	cfg := &serverConfig{
+		algorithm:     defaultAlg,
+		certLifetime:  defaultCertLen,
+		keyLength:     defaultKeyLen,
+		claimDefaults: jwtmock.ClaimDefaults{TTL: jwtmock.DefaultTokenTTL},
+	}
+	for _, option := range options {
+		option(cfg)
+	}
+
+	source := cfg.source()
+	cfg.claimDefaults.Rand = source
+	cfg.claimDefaults.Now = source.Now
+
+	certGenerator, err := service.NewCertificateGenerator(cfg.certLifetime, source)
+	if err != nil {
+		return nil, fmt.Errorf("init root CA: %w", err)
+	}
+
+	signingKeyGenerator, err := cfg.keyGenerator(source)
+	if err != nil {
+		return nil, fmt.Errorf("init key generator: %w", err)
+	}
+
+	keyID, err := service.NewKeyIDFunc(cfg.keyIDStrategy, cfg.fixedKeyID, cfg.keyIDPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("init key IDs: %w", err)
+	}
+
+	keyGenerator := jwks.NewGenerator(certGenerator, signingKeyGenerator, cfg.keyLength,
+		jwks.WithSymmetricKeyPublishing(cfg.publishSymmetric), jwks.WithKeyIDFunc(keyID))
+	keyStoreOptions, err := cfg.keyStoreOptions(source, keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	keyStore, err := service.NewKeyStore(keyGenerator, keyStoreOptions...)
 	if err != nil {
 		return nil, fmt.Errorf("init key store: %w", err)
 	}
 
+	for _, alg := range cfg.additionalKeys {
+		if _, err = keyStore.AddKey(alg); err != nil {
+			return nil, fmt.Errorf("add %v key: %w", alg, err)
+		}
+	}
+
 	logger := log.NewLogger(log.WithLevel(log.Debug))
 	clientRepo := service.NewClientRepo()
-	handler := handlers.NewHandler(keyStore, clientRepo, logger)
+	rootCert := certGenerator.RootCertificate()
+	handlerOptions := append(cfg.handlerOptions(), handlers.WithRootCertificate(service.EncodeCertificates(rootCert)))
+	handler := handlers.NewHandler(keyStore, clientRepo, logger, handlerOptions...)
 	server := httptest.NewServer(handler)
 
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var rotationDone <-chan struct{}
+	if cfg.rotationInterval > 0 {
+		rotationDone = keyStore.StartRotation(ctx, cfg.rotationInterval, logger)
+	}
+
 	return &Server{
-		Server:      server,
-		keystore:    keyStore,
-		clientsRepo: clientRepo,
+		Server:         server,
+		keystore:       keyStore,
+		clientsRepo:    clientRepo,
+		rootCert:       rootCert,
+		stopBackground: cancel,
+		rotationDone:   rotationDone,
+		renewalDone:    keyStore.StartCertificateRenewal(ctx, logger),
+		exportKeys:     cfg.exportKeys,
+		claimDefaults:  cfg.claimDefaults,
 	}, nil
 }
 
-// GenerateJWT generates a JWT token for use in authorization header.
-func (s *Server) GenerateJWT(claims jwtmock.Claims) (string, error) {
-	signingKey := s.keystore.GetSigningKey()
-	return claims.CreateJWT(signingKey)
+// Close shuts down the server and stops scheduled key rotation and certificate renewal.
+func (s *Server) Close() {
+	s.stopBackground()
+	if s.rotationDone != nil {
+		<-s.rotationDone
+	}
+
+	if s.renewalDone != nil {
+		<-s.renewalDone
+	}
+
+	s.Server.Close()
+}
+
+// GenerateJWT generates a JWT token for use in authorization header - missing "iat", "nbf", "exp", "jti", "iss" and
+// "aud" claims are filled from the server defaults, use a nil claim value to leave one out.
+// Use options to select the signing key, otherwise the current signing key is used. Use jwtmock.WithUncheckedClaims to
+// sign expired or otherwise invalid claims.
+func (s *Server) GenerateJWT(claims jwtmock.Claims, options ...jwtmock.TokenOption) (string, error) {
+	opts := jwtmock.NewTokenOptions(options...)
+
+	signingKey, err := s.keystore.FindSigningKey(opts.KeyID, opts.Algorithm)
+	if err != nil {
+		return "", err
+	}
+
+	claims, err = claims.WithDefaults(s.claimDefaults)
+	if err != nil {
+		return "", err
+	}
+
+	// claims are validated against the server clock unless the options set another clock
+	return claims.CreateJWT(signingKey, append([]jwtmock.TokenOption{jwtmock.WithClock(s.claimDefaults.Now)},
+		options...)...)
+}
+
+// KeySetURL returns the URL of a JWKS containing only the key with the given ID - for use with
+// jwtmock.WithKeySetURL. Staged keys resolve even though they are not in the JWKS.
+func (s *Server) KeySetURL(kid string) string {
+	return fmt.Sprintf("%v/jwtmock/jwks/%v", s.URL, url.PathEscape(kid))
+}
+
+// CertificateChainURL returns the URL of the PEM-encoded certificate chain of the key with the given ID - for use
+// with jwtmock.WithCertificateURL.
+func (s *Server) CertificateChainURL(kid string) string {
+	return fmt.Sprintf("%v/jwtmock/x509/%v", s.URL, url.PathEscape(kid))
+}
+
+// AddKey adds a new signing key for the given algorithm which is published alongside existing keys.
+// The server's default algorithm is used if none is given.
+func (s *Server) AddKey(alg jwa.SignatureAlgorithm) (*jwtmock.SigningKey, error) {
+	return s.keystore.AddKey(alg)
 }
 
 // RegisterClient registers a new client for subsequent token request.
 func (s *Server) RegisterClient(registration jwtmock.ClientRegistration) error {
 	return s.clientsRepo.Register(registration)
 }
+
+// HMACSecret returns a copy of the shared secret used to sign JWTs when using an HMAC signing algorithm.
+func (s *Server) HMACSecret() ([]byte, error) {
+	signingKey := s.keystore.GetSigningKey()
+	if !signingKey.IsSymmetric() {
+		return nil, ErrNoSharedSecret
+	}
+
+	secret := signingKey.Key.([]byte)
+
+	return append([]byte(nil), secret...), nil
+}
+
+// RotateKey replaces the current signing key with a new one, so tests can check that consumers refetch the JWKS.
+// JWTs signed by the previous key keep verifying for the given retention (forever if zero) - see RetireKey.
+func (s *Server) RotateKey(retention time.Duration) (*jwtmock.SigningKey, error) {
+	return s.keystore.RotateWithRetention(retention)
+}
+
+// StageKey adds a new signing key for the given algorithm (the default algorithm if empty) which signs JWTs but is
+// withheld from the JWKS until it is published - after the given delay, or by PublishKey if the delay is zero.
+// The staged key replaces the current signing key if current is true, otherwise use jwtmock.WithKeyID to sign with it.
+func (s *Server) StageKey(alg jwa.SignatureAlgorithm, publishAfter time.Duration,
+	current bool) (*jwtmock.SigningKey, error) {
+	return s.keystore.StageKey(alg, publishAfter, current)
+}
+
+// PublishKey adds the staged key with the given ID to the JWKS.
+func (s *Server) PublishKey(kid string) error {
+	return s.keystore.Publish(kid)
+}
+
+// RetireKey stops the key with the given ID from signing JWTs and removes it from the JWKS.
+func (s *Server) RetireKey(kid string) error {
+	return s.keystore.Retire(kid)
+}
+
+// ListKeys returns all signing keys held by the server along with their status - including the most recently retired
+// keys.
+func (s *Server) ListKeys() []jwtmock.KeyInfo {
+	return s.keystore.ListKeys()
+}
+
+// DeactivateKey stops the key with the given ID from signing JWTs, while JWTs it already signed keep verifying.
+// Use RetireKey to also remove it from the JWKS.
+func (s *Server) DeactivateKey(kid string) error {
+	return s.keystore.Deactivate(kid)
+}
+
+// ExportSigningKey returns the current signing key as PEM and as a private JWK so that JWTs can be signed
+// outside of the server. This requires the WithPrivateKeyExport option.
+func (s *Server) ExportSigningKey() (*jwtmock.PrivateKeyExport, error) {
+	if !s.exportKeys {
+		return nil, ErrExportDisabled
+	}
+
+	return service.ExportSigningKey(s.keystore.GetSigningKey())
+}
+
+// RootCA returns the root CA certificate that signs the certificates of generated keys (the last certificate in
+// "x5c"). Add it to a certificate pool to validate "x5c" against a trust anchor.
+func (s *Server) RootCA() *x509.Certificate {
+	return s.rootCert
+}
+
+// RootCAPEM returns the PEM-encoded root CA certificate - the same as served by the /jwtmock/ca.pem endpoint.
+func (s *Server) RootCAPEM() []byte {
+	return service.EncodeCertificates(s.rootCert)
+}
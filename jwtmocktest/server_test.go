@@ -2,10 +2,31 @@ package jwtmocktest
 
 import (
 	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1" // nolint:gosec // x5t is defined as a SHA-1 thumbprint
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jws"
 	"github.com/lestrrat-go/jwx/jwt"
 	"github.com/nayyara-cropsey/jwtmock"
 	"github.com/stretchr/testify/assert"
@@ -34,10 +55,1970 @@ func TestNewServer(t *testing.T) {
 	parsedToken, err := jwt.Parse(bytes.NewReader([]byte(token)), jwt.WithKeySet(jwsKeySet))
 	assert.NoError(t, err)
 
-	assert.NoError(t, jwt.Verify(parsedToken, jwt.WithSubject("olg387f")))
-	assert.NoError(t, jwt.Verify(parsedToken, jwt.WithIssuer("test")))
-	assert.NoError(t, jwt.Verify(parsedToken, jwt.WithClaimValue(jwt.IssuedAtKey, now)))
-	assert.NoError(t, jwt.Verify(parsedToken, jwt.WithClaimValue(jwt.ExpirationKey, exp)))
-	assert.NoError(t, jwt.Verify(parsedToken, jwt.WithClaimValue("email", "vibrant_greider@xxx.com")))
-	assert.NoError(t, jwt.Verify(parsedToken), jwt.WithKeySet(jwsKeySet))
+	// "nbf" is the current time, which jwx only accepts from the next second on
+	skew := jwt.WithAcceptableSkew(time.Second)
+
+	assert.NoError(t, jwt.Verify(parsedToken, skew, jwt.WithSubject("olg387f")))
+	assert.NoError(t, jwt.Verify(parsedToken, skew, jwt.WithIssuer("test")))
+	assert.NoError(t, jwt.Verify(parsedToken, skew, jwt.WithClaimValue(jwt.IssuedAtKey, now)))
+	assert.NoError(t, jwt.Verify(parsedToken, skew, jwt.WithClaimValue(jwt.ExpirationKey, exp)))
+	assert.NoError(t, jwt.Verify(parsedToken, skew, jwt.WithClaimValue("email", "vibrant_greider@xxx.com")))
+	assert.NoError(t, jwt.Verify(parsedToken, skew), jwt.WithKeySet(jwsKeySet))
+}
+
+func TestNewServer_DefaultClaims(t *testing.T) {
+	server, err := NewServer(WithTokenTTL(10*time.Minute), WithIssuer("https://jwtmock.test"),
+		WithAudience("api-a", "api-b"))
+	assert.NoError(t, err)
+
+	defer server.Close()
+
+	keySet, err := jwk.Fetch(server.URL + "/.well-known/jwks.json")
+	assert.NoError(t, err)
+
+	claims := jwtmock.Claims{jwt.SubjectKey: "olg387f"}
+	serverToken, err := server.GenerateJWT(claims)
+	assert.NoError(t, err)
+
+	clientToken, err := jwtmock.NewClient(server.URL).GenerateJWT(context.Background(), claims)
+	assert.NoError(t, err)
+
+	jwtIDs := map[string]bool{}
+	for _, token := range []string{serverToken, clientToken} {
+		parsedToken, err := jwt.Parse(bytes.NewReader([]byte(token)), jwt.WithKeySet(keySet))
+		if !assert.NoError(t, err) {
+			continue
+		}
+
+		assert.NoError(t, jwt.Verify(parsedToken, jwt.WithAcceptableSkew(time.Second),
+			jwt.WithIssuer("https://jwtmock.test"), jwt.WithAudience("api-b")))
+		assert.WithinDuration(t, time.Now(), parsedToken.IssuedAt(), 5*time.Second)
+		assert.Equal(t, parsedToken.IssuedAt(), parsedToken.NotBefore())
+		assert.Equal(t, parsedToken.IssuedAt().Add(10*time.Minute), parsedToken.Expiration())
+		assert.Len(t, parsedToken.JwtID(), 36)
+		assert.False(t, jwtIDs[parsedToken.JwtID()])
+
+		jwtIDs[parsedToken.JwtID()] = true
+	}
+
+	// given claims are kept and nil claims are left out
+	issuedAt := time.Now().Add(-time.Minute).Truncate(time.Second)
+	token, err := server.GenerateJWT(jwtmock.Claims{
+		jwt.SubjectKey:  "olg387f",
+		jwt.IssuedAtKey: issuedAt.Unix(),
+		jwt.IssuerKey:   "other",
+		jwt.JwtIDKey:    nil,
+	})
+	assert.NoError(t, err)
+
+	parsedToken, err := jwt.Parse(bytes.NewReader([]byte(token)), jwt.WithKeySet(keySet))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "other", parsedToken.Issuer())
+		assert.Equal(t, issuedAt.UTC(), parsedToken.NotBefore())
+		assert.Empty(t, parsedToken.JwtID())
+	}
+}
+
+func TestServer_UncheckedClaims(t *testing.T) {
+	server, err := NewServer()
+	assert.NoError(t, err)
+
+	defer server.Close()
+
+	jwsKeySet, err := jwk.Fetch(server.URL + "/.well-known/jwks.json")
+	assert.NoError(t, err)
+
+	client := jwtmock.NewClient(server.URL)
+	now := time.Now()
+	tests := map[string]jwtmock.Claims{
+		"expired": {
+			jwt.SubjectKey:    "olg387f",
+			jwt.ExpirationKey: now.Add(-time.Hour).Unix(),
+		},
+		"issued in the future": {
+			jwt.SubjectKey:    "olg387f",
+			jwt.IssuedAtKey:   now.Add(time.Hour).Unix(),
+			jwt.ExpirationKey: now.Add(2 * time.Hour).Unix(),
+		},
+		"not yet valid": {
+			jwt.SubjectKey:    "olg387f",
+			jwt.NotBeforeKey:  now.Add(time.Hour).Unix(),
+			jwt.ExpirationKey: now.Add(2 * time.Hour).Unix(),
+		},
+		"no subject": {
+			jwt.ExpirationKey: now.Add(time.Hour).Unix(),
+		},
+	}
+
+	for name, claims := range tests {
+		claims := claims
+
+		t.Run(name, func(t *testing.T) {
+			serverToken, err := server.GenerateJWT(claims, jwtmock.WithUncheckedClaims())
+			assert.NoError(t, err)
+
+			clientToken, err := client.GenerateJWT(context.Background(), claims, jwtmock.WithUncheckedClaims())
+			assert.NoError(t, err)
+
+			for _, token := range []string{serverToken, clientToken} {
+				// well-signed, but not valid
+				parsedToken, err := jwt.Parse(bytes.NewReader([]byte(token)), jwt.WithKeySet(jwsKeySet))
+				assert.NoError(t, err)
+				assert.False(t, jwt.Verify(parsedToken) == nil && parsedToken.Subject() != "")
+			}
+		})
+	}
+
+	_, err = server.GenerateJWT(tests["expired"])
+	assert.ErrorIs(t, err, jwtmock.ErrExpiredToken)
+}
+
+func TestServer_UncheckedClaimTypes(t *testing.T) {
+	server, err := NewServer()
+	assert.NoError(t, err)
+
+	defer server.Close()
+
+	jwsKeySet, err := jwk.Fetch(server.URL + "/.well-known/jwks.json")
+	assert.NoError(t, err)
+
+	var publicKey rsa.PublicKey
+	assert.NoError(t, jwsKeySet.Keys[0].Raw(&publicKey))
+
+	claims := jwtmock.Claims{
+		jwt.SubjectKey:    42,
+		jwt.ExpirationKey: "soon",
+		jwt.IssuedAtKey:   "yesterday",
+		jwt.AudienceKey:   5,
+	}
+
+	serverToken, err := server.GenerateJWT(claims, jwtmock.WithUncheckedClaims())
+	assert.NoError(t, err)
+
+	clientToken, err := jwtmock.NewClient(server.URL).GenerateJWT(context.Background(), claims,
+		jwtmock.WithUncheckedClaims())
+	assert.NoError(t, err)
+
+	for _, token := range []string{serverToken, clientToken} {
+		payload, err := jws.Verify([]byte(token), jwa.RS256, &publicKey)
+		if !assert.NoError(t, err) {
+			continue
+		}
+
+		var signed map[string]interface{}
+		assert.NoError(t, json.Unmarshal(payload, &signed))
+		assert.Equal(t, 42.0, signed[jwt.SubjectKey])
+		assert.Equal(t, "soon", signed[jwt.ExpirationKey])
+		assert.Equal(t, "yesterday", signed[jwt.IssuedAtKey])
+		assert.Equal(t, 5.0, signed[jwt.AudienceKey])
+	}
+
+	_, err = server.GenerateJWT(claims)
+	assert.Error(t, err)
+}
+
+func TestServer_MalformedTokens(t *testing.T) {
+	server, err := NewServer(WithSigningAlgorithm(jwa.ES256))
+	assert.NoError(t, err)
+
+	defer server.Close()
+
+	signingKey := server.keystore.GetSigningKey()
+	claims := jwtmock.Claims{
+		jwt.SubjectKey:    "olg387f",
+		jwt.ExpirationKey: time.Now().Add(time.Hour).Unix(),
+	}
+
+	tokens := make(map[jwtmock.MalformedToken]string)
+	for _, variant := range jwtmock.MalformedTokens {
+		token, err := server.GenerateJWT(claims, jwtmock.WithMalformedToken(variant))
+		assert.NoError(t, err)
+
+		tokens[variant] = token
+	}
+
+	segments := func(variant jwtmock.MalformedToken) []string {
+		return strings.Split(tokens[variant], ".")
+	}
+
+	decode := func(segment string) string {
+		decoded, err := base64.RawURLEncoding.DecodeString(segment)
+		assert.NoError(t, err)
+
+		return string(decoded)
+	}
+
+	verify := func(variant jwtmock.MalformedToken) (jws.Headers, error) {
+		msg, err := jws.Parse(strings.NewReader(tokens[variant]))
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = jws.Verify([]byte(tokens[variant]), jwa.ES256, signingKey.PublicKey)
+
+		return msg.Signatures()[0].ProtectedHeaders(), err
+	}
+
+	assert.Contains(t, decode(segments(jwtmock.MalformedAlgNone)[0]), `"alg":"none"`)
+	assert.Empty(t, segments(jwtmock.MalformedAlgNone)[2])
+	assert.Contains(t, decode(segments(jwtmock.MalformedSignatureStripped)[0]), `"alg":"ES256"`)
+	assert.Empty(t, segments(jwtmock.MalformedSignatureStripped)[2])
+	assert.Len(t, segments(jwtmock.MalformedTruncated), 2)
+
+	headers, err := verify(jwtmock.MalformedForeignKey)
+	assert.Error(t, err)
+	assert.Equal(t, signingKey.ID, headers.KeyID())
+
+	headers, err = verify(jwtmock.MalformedWrongKeyID)
+	assert.NoError(t, err)
+	assert.NotEqual(t, signingKey.ID, headers.KeyID())
+
+	headers, err = verify(jwtmock.MalformedMissingKeyID)
+	assert.NoError(t, err)
+	assert.Empty(t, headers.KeyID())
+
+	_, err = base64.RawURLEncoding.DecodeString(segments(jwtmock.MalformedInvalidBase64)[1])
+	assert.Error(t, err)
+
+	assert.Equal(t, 2, strings.Count(decode(segments(jwtmock.MalformedDuplicateClaims)[1]), `"sub":`))
+	_, err = verify(jwtmock.MalformedDuplicateClaims)
+	assert.NoError(t, err)
+
+	assert.Greater(t, len(segments(jwtmock.MalformedOversizedHeader)[0]), 64*1024)
+	_, err = verify(jwtmock.MalformedOversizedHeader)
+	assert.NoError(t, err)
+
+	// the real JWKS never verifies these tokens
+	jwsKeySet, err := jwk.Fetch(server.URL + "/.well-known/jwks.json")
+	assert.NoError(t, err)
+
+	for _, variant := range []jwtmock.MalformedToken{jwtmock.MalformedAlgNone, jwtmock.MalformedSignatureStripped,
+		jwtmock.MalformedForeignKey, jwtmock.MalformedWrongKeyID, jwtmock.MalformedTruncated} {
+		_, err = jwt.Parse(strings.NewReader(tokens[variant]), jwt.WithKeySet(jwsKeySet))
+		assert.Error(t, err, variant)
+	}
+
+	client := jwtmock.NewClient(server.URL)
+	token, err := client.GenerateJWT(context.Background(), claims, jwtmock.WithMalformedToken(jwtmock.MalformedAlgNone))
+	assert.NoError(t, err)
+	assert.Contains(t, decode(strings.Split(token, ".")[0]), `"alg":"none"`)
+
+	_, err = client.GenerateJWT(context.Background(), claims, jwtmock.WithMalformedToken("unknown"))
+	assert.Error(t, err)
+}
+
+func TestServer_AlgorithmConfusion(t *testing.T) {
+	server, err := NewServer()
+	assert.NoError(t, err)
+
+	defer server.Close()
+
+	jwsKeySet, err := jwk.Fetch(server.URL + "/.well-known/jwks.json")
+	assert.NoError(t, err)
+
+	signingKey := server.keystore.GetSigningKey()
+	claims := jwtmock.Claims{
+		jwt.SubjectKey:    "olg387f",
+		jwt.ExpirationKey: time.Now().Add(time.Hour).Unix(),
+	}
+	client := jwtmock.NewClient(server.URL)
+
+	for _, encoding := range jwtmock.PublicKeyEncodings {
+		secret, err := jwtmock.EncodePublicKey(signingKey.PublicKey, encoding)
+		assert.NoError(t, err)
+
+		for _, alg := range []jwa.SignatureAlgorithm{jwa.HS256, jwa.HS384, jwa.HS512} {
+			serverToken, err := server.GenerateJWT(claims, jwtmock.WithAlgorithmConfusion(alg, encoding))
+			assert.NoError(t, err)
+
+			clientToken, err := client.GenerateJWT(context.Background(), claims,
+				jwtmock.WithAlgorithmConfusion(alg, encoding))
+			assert.NoError(t, err)
+
+			for _, token := range []string{serverToken, clientToken} {
+				// the public key verifies the HMAC signature, but the JWKS does not
+				_, err = jws.Verify([]byte(token), alg, secret)
+				assert.NoError(t, err, encoding)
+
+				msg, err := jws.Parse(strings.NewReader(token))
+				if assert.NoError(t, err) {
+					assert.Equal(t, signingKey.ID, msg.Signatures()[0].ProtectedHeaders().KeyID())
+				}
+
+				_, err = jwt.Parse(strings.NewReader(token), jwt.WithKeySet(jwsKeySet))
+				assert.Error(t, err)
+			}
+		}
+	}
+
+	// headers pointing consumers at the signing key are kept
+	for _, token := range []func() (string, error){
+		func() (string, error) {
+			return server.GenerateJWT(claims, jwtmock.WithAlgorithmConfusion(jwa.HS256, jwtmock.PublicKeyPEM),
+				jwtmock.WithKeySetURL(server.KeySetURL(signingKey.ID)),
+				jwtmock.WithCertificateURL(server.CertificateChainURL(signingKey.ID)), jwtmock.WithCertificateChain())
+		},
+		func() (string, error) {
+			return client.GenerateJWT(context.Background(), claims,
+				jwtmock.WithAlgorithmConfusion(jwa.HS256, jwtmock.PublicKeyPEM),
+				jwtmock.WithKeySetURL(client.KeySetURL(signingKey.ID)),
+				jwtmock.WithCertificateURL(client.CertificateChainURL(signingKey.ID)), jwtmock.WithCertificateChain())
+		},
+	} {
+		token, err := token()
+		if !assert.NoError(t, err) {
+			continue
+		}
+
+		msg, err := jws.Parse(strings.NewReader(token))
+		if assert.NoError(t, err) {
+			headers := msg.Signatures()[0].ProtectedHeaders()
+			assert.Equal(t, server.KeySetURL(signingKey.ID), headers.JWKSetURL())
+			assert.Equal(t, server.CertificateChainURL(signingKey.ID), headers.X509URL())
+			assert.Len(t, headers.X509CertChain(), len(signingKey.Certificates))
+		}
+	}
+
+	_, err = server.GenerateJWT(claims, jwtmock.WithAlgorithmConfusion(jwa.RS256, jwtmock.PublicKeyPEM))
+	assert.ErrorIs(t, err, jwtmock.ErrUnsupportedConfusion)
+
+	_, err = server.GenerateJWT(claims, jwtmock.WithAlgorithmConfusion("", "unknown"))
+	assert.ErrorIs(t, err, jwtmock.ErrUnsupportedConfusion)
+}
+
+func TestNewServer_SigningAlgorithm(t *testing.T) {
+	for _, alg := range []jwa.SignatureAlgorithm{
+		jwa.RS256, jwa.RS384, jwa.RS512, jwa.PS256, jwa.PS384, jwa.PS512,
+		jwa.ES256, jwa.ES384, jwa.ES512, jwa.EdDSA,
+	} {
+		alg := alg
+		t.Run(alg.String(), func(t *testing.T) {
+			server, err := NewServer(WithSigningAlgorithm(alg))
+			assert.NoError(t, err)
+
+			defer server.Close()
+
+			now := time.Now()
+			token, err := server.GenerateJWT(jwtmock.Claims{
+				jwt.SubjectKey:    "olg387f",
+				jwt.IssuedAtKey:   now.Unix(),
+				jwt.ExpirationKey: now.Add(time.Hour).Unix(),
+			})
+			assert.NoError(t, err)
+
+			msg, err := jws.ParseString(token)
+			assert.NoError(t, err)
+			assert.Equal(t, alg, msg.Signatures()[0].ProtectedHeaders().Algorithm())
+
+			jwsKeySet, err := jwk.Fetch(server.URL + "/.well-known/jwks.json")
+			assert.NoError(t, err)
+
+			assert.Equal(t, alg.String(), jwsKeySet.Keys[0].Algorithm())
+
+			_, err = jwt.Parse(bytes.NewReader([]byte(token)), jwt.WithKeySet(jwsKeySet))
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestNewServer_UnsupportedAlgorithm(t *testing.T) {
+	_, err := NewServer(WithSigningAlgorithm(jwa.NoSignature))
+	assert.Error(t, err)
+}
+
+func TestNewServer_EdDSAKeySet(t *testing.T) {
+	server, err := NewServer(WithSigningAlgorithm(jwa.EdDSA))
+	assert.NoError(t, err)
+
+	defer server.Close()
+
+	jwsKeySet, err := jwk.Fetch(server.URL + "/.well-known/jwks.json")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, jwsKeySet.Len())
+
+	key, ok := jwsKeySet.Keys[0].(jwk.OKPPublicKey)
+	assert.True(t, ok)
+	assert.Equal(t, jwa.OKP, key.KeyType())
+	assert.Equal(t, jwa.Ed25519, key.Crv())
+	assert.Equal(t, jwa.EdDSA.String(), key.Algorithm())
+	assert.Len(t, key.X509CertChain(), 2)
+}
+
+func TestNewServer_KeySetJSON(t *testing.T) {
+	server, err := NewServer()
+	assert.NoError(t, err)
+
+	defer server.Close()
+
+	var keySet map[string][]map[string]interface{}
+	assert.Equal(t, http.StatusOK, doJSON(t, http.MethodGet, server.URL+"/.well-known/jwks.json", &keySet))
+	assert.Len(t, keySet["keys"], 1)
+}
+
+func TestNewServer_KeySetThumbprints(t *testing.T) {
+	for _, alg := range []jwa.SignatureAlgorithm{jwa.RS256, jwa.ES256, jwa.EdDSA} {
+		alg := alg
+		t.Run(alg.String(), func(t *testing.T) {
+			server, err := NewServer(WithSigningAlgorithm(alg))
+			assert.NoError(t, err)
+
+			defer server.Close()
+
+			var keySet struct {
+				Keys []struct {
+					X5C     []string `json:"x5c"`
+					X5T     string   `json:"x5t"`
+					X5TS256 string   `json:"x5t#S256"`
+				} `json:"keys"`
+			}
+			assert.Equal(t, http.StatusOK, doJSON(t, http.MethodGet, server.URL+"/.well-known/jwks.json", &keySet))
+			assert.Len(t, keySet.Keys, 1)
+
+			der, err := base64.StdEncoding.DecodeString(keySet.Keys[0].X5C[0])
+			assert.NoError(t, err)
+
+			// nolint:gosec // x5t is defined as a SHA-1 thumbprint
+			x5t := sha1.Sum(der)
+			x5tS256 := sha256.Sum256(der)
+			assert.Equal(t, base64.RawURLEncoding.EncodeToString(x5t[:]), keySet.Keys[0].X5T)
+			assert.Equal(t, base64.RawURLEncoding.EncodeToString(x5tS256[:]), keySet.Keys[0].X5TS256)
+
+			jwsKeySet, err := jwk.Fetch(server.URL + "/.well-known/jwks.json")
+			assert.NoError(t, err)
+			assert.NoError(t, ValidateKeySet(jwsKeySet))
+		})
+	}
+}
+
+func TestValidateKeySet_NonConforming(t *testing.T) {
+	server, err := NewServer(WithAdditionalKeys(jwa.ES256))
+	assert.NoError(t, err)
+
+	defer server.Close()
+
+	jwsKeySet, err := jwk.Fetch(server.URL + "/.well-known/jwks.json")
+	assert.NoError(t, err)
+	assert.NoError(t, ValidateKeySet(jwsKeySet))
+
+	// hex-encoded thumbprints are not base64url
+	key := jwsKeySet.Keys[0]
+	x5t := sha1.Sum(key.X509CertChain()[0].Raw) // nolint:gosec // x5t is defined as a SHA-1 thumbprint
+	assert.NoError(t, key.Set(jwk.X509CertThumbprintKey, hex.EncodeToString(x5t[:])))
+	assert.ErrorIs(t, ValidateKeySet(jwsKeySet), ErrNonConformingKeySet)
+
+	// certificate chain of another key
+	assert.NoError(t, key.Set(jwk.X509CertThumbprintKey, base64.RawURLEncoding.EncodeToString(x5t[:])))
+	assert.NoError(t, ValidateKeySet(jwsKeySet))
+	x5c := []string{base64.StdEncoding.EncodeToString(key.X509CertChain()[0].Raw)}
+	assert.NoError(t, jwsKeySet.Keys[1].Set(jwk.X509CertChainKey, x5c))
+	assert.NoError(t, jwsKeySet.Keys[1].Set(jwk.X509CertThumbprintKey, ""))
+	assert.NoError(t, jwsKeySet.Keys[1].Set(jwk.X509CertThumbprintS256Key, ""))
+	assert.ErrorIs(t, ValidateKeySet(jwsKeySet), ErrNonConformingKeySet)
+
+	// duplicate key IDs
+	jwsKeySet.Keys = []jwk.Key{key, key}
+	assert.ErrorIs(t, ValidateKeySet(jwsKeySet), ErrNonConformingKeySet)
+
+	// key IDs are optional - keys without one are still checked
+	jwsKeySet, err = jwk.Fetch(server.URL + "/.well-known/jwks.json")
+	assert.NoError(t, err)
+
+	for _, key := range jwsKeySet.Keys {
+		assert.NoError(t, key.Set(jwk.KeyIDKey, ""))
+	}
+
+	assert.NoError(t, ValidateKeySet(jwsKeySet))
+
+	assert.NoError(t, jwsKeySet.Keys[1].Set(jwk.X509CertThumbprintKey, hex.EncodeToString(x5t[:])))
+	assert.ErrorIs(t, ValidateKeySet(jwsKeySet), ErrNonConformingKeySet)
+}
+
+func TestServer_RootCA(t *testing.T) {
+	server, err := NewServer(WithAdditionalKeys(jwa.ES256, jwa.EdDSA))
+	assert.NoError(t, err)
+
+	defer server.Close()
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, server.URL+"/jwtmock/ca.pem", http.NoBody)
+	assert.NoError(t, err)
+
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	rootPEM, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, server.RootCAPEM(), rootPEM)
+
+	roots := x509.NewCertPool()
+	assert.True(t, roots.AppendCertsFromPEM(rootPEM))
+
+	jwsKeySet, err := jwk.Fetch(server.URL + "/.well-known/jwks.json")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, jwsKeySet.Len())
+
+	for _, key := range jwsKeySet.Keys {
+		chain := key.X509CertChain()
+		assert.Len(t, chain, 2)
+		assert.True(t, chain[1].Equal(server.RootCA()))
+
+		_, err = chain[0].Verify(x509.VerifyOptions{Roots: roots})
+		assert.NoError(t, err)
+	}
+}
+
+// doJSON sends a request without a body and decodes the JSON response into v (if not nil) returning the status.
+func TestNewServer_PEMKeys(t *testing.T) {
+	server, err := NewServer(WithAdditionalKeys(jwa.ES256))
+	assert.NoError(t, err)
+
+	defer server.Close()
+
+	jwsKeySet, err := jwk.Fetch(server.URL + "/.well-known/jwks.json")
+	assert.NoError(t, err)
+
+	var certs map[string]string
+	assert.Equal(t, http.StatusOK, doJSON(t, http.MethodGet, server.URL+"/jwtmock/x509", &certs))
+	assert.Len(t, certs, 2)
+
+	for _, key := range jwsKeySet.Keys {
+		block, _ := pem.Decode([]byte(certs[key.KeyID()]))
+		if assert.NotNil(t, block) {
+			assert.Equal(t, key.X509CertChain()[0].Raw, block.Bytes)
+		}
+	}
+
+	signingKey := server.keystore.GetSigningKey()
+	token, err := server.GenerateJWT(jwtmock.Claims{
+		jwt.SubjectKey:    "olg387f",
+		jwt.ExpirationKey: time.Now().Add(time.Hour).Unix(),
+	})
+	assert.NoError(t, err)
+
+	for _, path := range []string{"/jwtmock/pem", "/jwtmock/pem/" + signingKey.ID} {
+		resp, err := http.Get(server.URL + path)
+		assert.NoError(t, err)
+
+		pemBytes, err := io.ReadAll(resp.Body)
+		assert.NoError(t, err)
+		assert.NoError(t, resp.Body.Close())
+		assert.Equal(t, "application/x-pem-file", resp.Header.Get("Content-Type"))
+
+		block, _ := pem.Decode(pemBytes)
+		if !assert.NotNil(t, block) {
+			continue
+		}
+
+		publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+		assert.NoError(t, err)
+
+		_, err = jws.Verify([]byte(token), jwa.RS256, publicKey)
+		assert.NoError(t, err)
+	}
+
+	resp, err := http.Get(server.URL + "/jwtmock/pem/unknown")
+	assert.NoError(t, err)
+	assert.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestServer_KeyURLHeaders(t *testing.T) {
+	server, err := NewServer()
+	assert.NoError(t, err)
+
+	defer server.Close()
+
+	// staged keys are not in the JWKS but resolve through "jku" and "x5u"
+	signingKey, err := server.StageKey("", 0, false)
+	assert.NoError(t, err)
+
+	claims := jwtmock.Claims{
+		jwt.SubjectKey:    "olg387f",
+		jwt.ExpirationKey: time.Now().Add(time.Hour).Unix(),
+	}
+	options := []jwtmock.TokenOption{
+		jwtmock.WithKeyID(signingKey.ID),
+		jwtmock.WithKeySetURL(server.KeySetURL(signingKey.ID)),
+		jwtmock.WithCertificateURL(server.CertificateChainURL(signingKey.ID)),
+		jwtmock.WithCertificateChain(),
+	}
+
+	serverToken, err := server.GenerateJWT(claims, options...)
+	assert.NoError(t, err)
+
+	clientToken, err := jwtmock.NewClient(server.URL).GenerateJWT(context.Background(), claims, options...)
+	assert.NoError(t, err)
+
+	for _, token := range []string{serverToken, clientToken} {
+		msg, err := jws.Parse(bytes.NewReader([]byte(token)))
+		if !assert.NoError(t, err) {
+			continue
+		}
+
+		headers := msg.Signatures()[0].ProtectedHeaders()
+		assert.Equal(t, server.KeySetURL(signingKey.ID), headers.JWKSetURL())
+		assert.Equal(t, server.CertificateChainURL(signingKey.ID), headers.X509URL())
+		assert.Len(t, headers.X509CertChain(), 2)
+
+		keySet, err := jwk.Fetch(headers.JWKSetURL())
+		assert.NoError(t, err)
+		assert.Equal(t, 1, keySet.Len())
+
+		_, err = jwt.Parse(bytes.NewReader([]byte(token)), jwt.WithKeySet(keySet))
+		assert.NoError(t, err)
+
+		resp, err := http.Get(headers.X509URL())
+		assert.NoError(t, err)
+
+		chainPEM, err := io.ReadAll(resp.Body)
+		assert.NoError(t, err)
+		assert.NoError(t, resp.Body.Close())
+
+		for _, certStr := range headers.X509CertChain() {
+			var block *pem.Block
+			block, chainPEM = pem.Decode(chainPEM)
+			if assert.NotNil(t, block) {
+				assert.Equal(t, certStr, base64.StdEncoding.EncodeToString(block.Bytes))
+			}
+		}
+	}
+
+	resp, err := http.Get(server.KeySetURL("unknown"))
+	assert.NoError(t, err)
+	assert.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestServer_CustomHeaders(t *testing.T) {
+	server, err := NewServer()
+	assert.NoError(t, err)
+
+	defer server.Close()
+
+	signingKey, err := server.AddKey(jwa.ES256)
+	assert.NoError(t, err)
+
+	claims := jwtmock.Claims{
+		jwt.SubjectKey:    "olg387f",
+		jwt.ExpirationKey: time.Now().Add(time.Hour).Unix(),
+	}
+	options := []jwtmock.TokenOption{
+		jwtmock.WithKeyID(signingKey.ID),
+		jwtmock.WithHeaders(jwtmock.Headers{
+			jws.TypeKey:        "at+jwt",
+			jws.ContentTypeKey: "JWT",
+			jws.CriticalKey:    []string{"x-tenant"},
+			"x-tenant":         "acme",
+		}),
+	}
+
+	serverToken, err := server.GenerateJWT(claims, options...)
+	assert.NoError(t, err)
+
+	clientToken, err := jwtmock.NewClient(server.URL).GenerateJWT(context.Background(), claims, options...)
+	assert.NoError(t, err)
+
+	// the "kid" header is suppressed
+	options = append(options, jwtmock.WithHeaders(jwtmock.Headers{jws.KeyIDKey: nil}))
+	noKeyIDToken, err := jwtmock.NewClient(server.URL).GenerateJWT(context.Background(), claims, options...)
+	assert.NoError(t, err)
+
+	for _, token := range []string{serverToken, clientToken, noKeyIDToken} {
+		msg, err := jws.Parse(bytes.NewReader([]byte(token)))
+		if !assert.NoError(t, err) {
+			continue
+		}
+
+		headers := msg.Signatures()[0].ProtectedHeaders()
+		assert.Equal(t, "at+jwt", headers.Type())
+		assert.Equal(t, "JWT", headers.ContentType())
+		assert.Equal(t, []string{"x-tenant"}, headers.Critical())
+		assert.Equal(t, jwa.ES256, headers.Algorithm())
+
+		tenant, ok := headers.Get("x-tenant")
+		assert.True(t, ok)
+		assert.Equal(t, "acme", tenant)
+
+		_, err = jws.Verify([]byte(token), jwa.ES256, signingKey.PublicKey)
+		assert.NoError(t, err)
+
+		if token == noKeyIDToken {
+			assert.Empty(t, headers.KeyID())
+		} else {
+			assert.Equal(t, signingKey.ID, headers.KeyID())
+		}
+	}
+}
+
+func TestServer_JWTRequestBody(t *testing.T) {
+	server, err := NewServer()
+	assert.NoError(t, err)
+
+	defer server.Close()
+
+	jwsKeySet, err := jwk.Fetch(server.URL + "/.well-known/jwks.json")
+	assert.NoError(t, err)
+
+	noHeaders, err := json.Marshal(jwtmock.JWTRequest{Claims: jwtmock.Claims{jwt.SubjectKey: "olg387f"}})
+	assert.NoError(t, err)
+
+	tests := map[string]string{
+		"claims":             `{"sub": "olg387f"}`,
+		"claims only":        `{"claims": {"sub": "olg387f"}}`,
+		"null headers":       `{"claims": {"sub": "olg387f"}, "headers": null}`,
+		"JWT request":        string(noHeaders),
+		"claims and headers": `{"claims": {"sub": "olg387f"}, "headers": {"typ": "at+jwt"}}`,
+	}
+
+	for name, body := range tests {
+		body := body
+
+		t.Run(name, func(t *testing.T) {
+			resp, err := http.Post(server.URL+"/jwtmock/generate-jwt", "application/json", strings.NewReader(body))
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			defer resp.Body.Close()
+
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+			var jwtResp struct {
+				Token string `json:"token"`
+			}
+			assert.NoError(t, json.NewDecoder(resp.Body).Decode(&jwtResp))
+
+			parsedToken, err := jwt.Parse(bytes.NewReader([]byte(jwtResp.Token)), jwt.WithKeySet(jwsKeySet))
+			if assert.NoError(t, err) {
+				assert.Equal(t, "olg387f", parsedToken.Subject())
+			}
+		})
+	}
+
+	// claims mixed into a JWT request are rejected rather than dropped
+	for _, body := range []string{
+		`{"claims": {"sub": "olg387f"}, "headers": "at+jwt"}`,
+		`{"sub": "olg387f", "claims": {"role": "admin"}}`,
+		`{"sub": "olg387f", "headers": {"typ": "at+jwt"}}`,
+		`{"claims": "olg387f"}`,
+	} {
+		resp, err := http.Post(server.URL+"/jwtmock/generate-jwt", "application/json", strings.NewReader(body))
+		if assert.NoError(t, err) {
+			assert.NoError(t, resp.Body.Close())
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, body)
+		}
+	}
+}
+
+func TestClient_GenerateJWTClaimsClaim(t *testing.T) {
+	server, err := NewServer()
+	assert.NoError(t, err)
+
+	defer server.Close()
+
+	// claims named "claims" and "headers" are sent as claims
+	token, err := jwtmock.NewClient(server.URL).GenerateJWT(context.Background(), jwtmock.Claims{
+		jwt.SubjectKey: "olg387f",
+		"claims":       map[string]interface{}{"role": "admin"},
+		"headers":      "none",
+	})
+	assert.NoError(t, err)
+
+	jwsKeySet, err := jwk.Fetch(server.URL + "/.well-known/jwks.json")
+	assert.NoError(t, err)
+
+	parsedToken, err := jwt.Parse(bytes.NewReader([]byte(token)), jwt.WithKeySet(jwsKeySet))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "olg387f", parsedToken.Subject())
+
+		claims, ok := parsedToken.Get("claims")
+		assert.True(t, ok)
+		assert.Equal(t, map[string]interface{}{"role": "admin"}, claims)
+	}
+}
+
+func doJSON(t *testing.T, method, url string, v interface{}) int {
+	t.Helper()
+
+	req, err := http.NewRequestWithContext(context.Background(), method, url, http.NoBody)
+	assert.NoError(t, err)
+
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	if v != nil {
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(v))
+	}
+
+	return resp.StatusCode
+}
+
+func TestNewServer_DecoyKeys(t *testing.T) {
+	server, err := NewServer(WithDecoyKeys(jwtmock.DecoyEncryption, jwtmock.DecoyUnknownKeyType,
+		jwtmock.DecoyMissingAlgorithm, jwtmock.DecoyDuplicateKeyID))
+	assert.NoError(t, err)
+
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/.well-known/jwks.json")
+	assert.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	var keySet struct {
+		Keys []map[string]interface{} `json:"keys"`
+	}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&keySet))
+	assert.Len(t, keySet.Keys, 5)
+
+	kid := server.keystore.GetSigningKey().ID
+
+	assert.Equal(t, "enc", keySet.Keys[0]["use"])
+	assert.Equal(t, "X-JWT-MOCK", keySet.Keys[1]["kty"])
+	assert.NotContains(t, keySet.Keys[2], "alg")
+	assert.Equal(t, kid, keySet.Keys[3]["kid"])
+	assert.Equal(t, "EC", keySet.Keys[3]["kty"])
+	assert.Equal(t, kid, keySet.Keys[4]["kid"])
+	assert.Equal(t, "RSA", keySet.Keys[4]["kty"])
+
+	// the duplicate key ID follows the current signing key
+	signingKey, err := server.RotateKey(0)
+	assert.NoError(t, err)
+
+	keys := server.keystore.GetJWKS().Keys
+	assert.Len(t, keys, 6)
+	assert.Equal(t, signingKey.ID, keys[3].KeyID())
+}
+
+func TestNewServer_UnsupportedDecoyKey(t *testing.T) {
+	_, err := NewServer(WithDecoyKeys("unknown"))
+	assert.Error(t, err)
+}
+
+func TestNewServer_HMAC(t *testing.T) {
+	secret := []byte("a-shared-secret-of-at-least-32-bytes")
+	server, err := NewServer(WithSigningAlgorithm(jwa.HS256), WithHMACSecret(secret), WithPrivateKeyExport())
+	assert.NoError(t, err)
+
+	defer server.Close()
+
+	serverSecret, err := server.HMACSecret()
+	assert.NoError(t, err)
+	assert.Equal(t, secret, serverSecret)
+
+	// the secret can't be changed through the copy
+	serverSecret[0] = 'A'
+	serverSecret, err = server.HMACSecret()
+	assert.NoError(t, err)
+	assert.Equal(t, secret, serverSecret)
+
+	now := time.Now()
+	token, err := server.GenerateJWT(jwtmock.Claims{
+		jwt.SubjectKey:    "olg387f",
+		jwt.IssuedAtKey:   now.Unix(),
+		jwt.ExpirationKey: now.Add(time.Hour).Unix(),
+	})
+	assert.NoError(t, err)
+
+	parsedToken, err := jwt.ParseVerify(bytes.NewReader([]byte(token)), jwa.HS256, secret)
+	assert.NoError(t, err)
+	assert.Equal(t, "olg387f", parsedToken.Subject())
+
+	var secretResp map[string]string
+	assert.Equal(t, http.StatusOK, doJSON(t, http.MethodGet, server.URL+"/jwtmock/secret", &secretResp))
+	assert.Equal(t, base64.RawURLEncoding.EncodeToString(secret), secretResp["secret"])
+	assert.Equal(t, "HS256", secretResp["alg"])
+
+	var keySet map[string][]map[string]interface{}
+	assert.Equal(t, http.StatusOK, doJSON(t, http.MethodGet, server.URL+"/.well-known/jwks.json", &keySet))
+	assert.Empty(t, keySet["keys"])
+}
+
+func TestNewServer_HMACPublished(t *testing.T) {
+	server, err := NewServer(WithSigningAlgorithm(jwa.HS512), WithSymmetricKeyPublishing())
+	assert.NoError(t, err)
+
+	defer server.Close()
+
+	secret, err := server.HMACSecret()
+	assert.NoError(t, err)
+	assert.Len(t, secret, 64)
+
+	// the secret is only served when key export is enabled
+	assert.Equal(t, http.StatusNotFound, doJSON(t, http.MethodGet, server.URL+"/jwtmock/secret", nil))
+
+	var keySet map[string][]map[string]interface{}
+	assert.Equal(t, http.StatusOK, doJSON(t, http.MethodGet, server.URL+"/.well-known/jwks.json", &keySet))
+	assert.Len(t, keySet["keys"], 1)
+	assert.Equal(t, "oct", keySet["keys"][0]["kty"])
+	assert.Equal(t, base64.RawURLEncoding.EncodeToString(secret), keySet["keys"][0]["k"])
+}
+
+func TestServer_HMACSecretNotSymmetric(t *testing.T) {
+	server, err := NewServer(WithPrivateKeyExport())
+	assert.NoError(t, err)
+
+	defer server.Close()
+
+	_, err = server.HMACSecret()
+	assert.ErrorIs(t, err, ErrNoSharedSecret)
+	assert.Equal(t, http.StatusNotFound, doJSON(t, http.MethodGet, server.URL+"/jwtmock/secret", nil))
+}
+
+func TestNewServer_MultipleKeys(t *testing.T) {
+	server, err := NewServer(WithAdditionalKeys(jwa.ES256, jwa.EdDSA))
+	assert.NoError(t, err)
+
+	defer server.Close()
+
+	jwsKeySet, err := jwk.Fetch(server.URL + "/.well-known/jwks.json")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, jwsKeySet.Len())
+
+	claims := jwtmock.Claims{
+		jwt.SubjectKey:    "olg387f",
+		jwt.IssuedAtKey:   time.Now().Unix(),
+		jwt.ExpirationKey: time.Now().Add(time.Hour).Unix(),
+	}
+
+	for _, key := range jwsKeySet.Keys {
+		token, err := server.GenerateJWT(claims, jwtmock.WithKeyID(key.KeyID()))
+		assert.NoError(t, err)
+
+		msg, err := jws.ParseString(token)
+		assert.NoError(t, err)
+		assert.Equal(t, key.KeyID(), msg.Signatures()[0].ProtectedHeaders().KeyID())
+
+		_, err = jwt.Parse(bytes.NewReader([]byte(token)), jwt.WithKeySet(jwsKeySet))
+		assert.NoError(t, err)
+	}
+
+	token, err := server.GenerateJWT(claims, jwtmock.WithAlgorithm(jwa.EdDSA))
+	assert.NoError(t, err)
+
+	msg, err := jws.ParseString(token)
+	assert.NoError(t, err)
+	assert.Equal(t, jwa.EdDSA, msg.Signatures()[0].ProtectedHeaders().Algorithm())
+
+	_, err = server.GenerateJWT(claims, jwtmock.WithKeyID("unknown"))
+	assert.Error(t, err)
+
+	_, err = server.GenerateJWT(claims, jwtmock.WithAlgorithm(jwa.ES512))
+	assert.Error(t, err)
+}
+
+func TestClient_MultipleKeys(t *testing.T) {
+	server, err := NewServer()
+	assert.NoError(t, err)
+
+	defer server.Close()
+
+	ctx := context.Background()
+	client := jwtmock.NewClient(server.URL)
+
+	keyInfo, err := client.AddKey(ctx, jwa.ES384)
+	assert.NoError(t, err)
+	assert.Equal(t, "ES384", keyInfo.Algorithm)
+
+	_, err = client.AddKey(ctx, "XYZ")
+	assert.Error(t, err)
+
+	jwsKeySet, err := jwk.Fetch(server.URL + "/.well-known/jwks.json")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, jwsKeySet.Len())
+
+	claims := jwtmock.Claims{
+		jwt.SubjectKey:    "olg387f",
+		jwt.IssuedAtKey:   time.Now().Unix(),
+		jwt.ExpirationKey: time.Now().Add(time.Hour).Unix(),
+	}
+
+	token, err := client.GenerateJWT(ctx, claims, jwtmock.WithKeyID(keyInfo.ID))
+	assert.NoError(t, err)
+
+	msg, err := jws.ParseString(token)
+	assert.NoError(t, err)
+	assert.Equal(t, keyInfo.ID, msg.Signatures()[0].ProtectedHeaders().KeyID())
+	assert.Equal(t, jwa.ES384, msg.Signatures()[0].ProtectedHeaders().Algorithm())
+
+	_, err = jwt.Parse(bytes.NewReader([]byte(token)), jwt.WithKeySet(jwsKeySet))
+	assert.NoError(t, err)
+
+	_, err = client.GenerateJWT(ctx, claims, jwtmock.WithKeyID("unknown"))
+	assert.Error(t, err)
+}
+
+func TestServer_RotateKey(t *testing.T) {
+	server, err := NewServer()
+	assert.NoError(t, err)
+
+	defer server.Close()
+
+	claims := jwtmock.Claims{
+		jwt.SubjectKey:    "olg387f",
+		jwt.IssuedAtKey:   time.Now().Unix(),
+		jwt.ExpirationKey: time.Now().Add(time.Hour).Unix(),
+	}
+
+	oldToken, err := server.GenerateJWT(claims)
+	assert.NoError(t, err)
+
+	newKey, err := server.RotateKey(0)
+	assert.NoError(t, err)
+
+	jwsKeySet, err := jwk.Fetch(server.URL + "/.well-known/jwks.json")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, jwsKeySet.Len())
+
+	// tokens signed before rotation are still verifiable
+	_, err = jwt.Parse(bytes.NewReader([]byte(oldToken)), jwt.WithKeySet(jwsKeySet))
+	assert.NoError(t, err)
+
+	newToken, err := server.GenerateJWT(claims)
+	assert.NoError(t, err)
+
+	msg, err := jws.ParseString(newToken)
+	assert.NoError(t, err)
+	assert.Equal(t, newKey.ID, msg.Signatures()[0].ProtectedHeaders().KeyID())
+
+	oldMsg, err := jws.ParseString(oldToken)
+	assert.NoError(t, err)
+
+	oldKeyID := oldMsg.Signatures()[0].ProtectedHeaders().KeyID()
+
+	// rotated keys no longer sign
+	_, err = server.GenerateJWT(claims, jwtmock.WithKeyID(oldKeyID))
+	assert.Error(t, err)
+
+	assert.Error(t, server.RetireKey(newKey.ID))
+	assert.NoError(t, server.RetireKey(oldKeyID))
+
+	jwsKeySet, err = jwk.Fetch(server.URL + "/.well-known/jwks.json")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, jwsKeySet.Len())
+	assert.Equal(t, newKey.ID, jwsKeySet.Keys[0].KeyID())
+
+	_, err = jwt.Parse(bytes.NewReader([]byte(oldToken)), jwt.WithKeySet(jwsKeySet))
+	assert.Error(t, err)
+}
+
+func TestServer_RotateKeyRetention(t *testing.T) {
+	server, err := NewServer()
+	assert.NoError(t, err)
+
+	defer server.Close()
+
+	newKey, err := server.RotateKey(500 * time.Millisecond)
+	assert.NoError(t, err)
+
+	jwsKeySet, err := jwk.Fetch(server.URL + "/.well-known/jwks.json")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, jwsKeySet.Len())
+
+	time.Sleep(time.Second)
+
+	jwsKeySet, err = jwk.Fetch(server.URL + "/.well-known/jwks.json")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, jwsKeySet.Len())
+	assert.Equal(t, newKey.ID, jwsKeySet.Keys[0].KeyID())
+}
+
+func TestClient_RotateKey(t *testing.T) {
+	server, err := NewServer()
+	assert.NoError(t, err)
+
+	defer server.Close()
+
+	ctx := context.Background()
+	client := jwtmock.NewClient(server.URL)
+
+	oldKeyID := server.keystore.GetSigningKey().ID
+
+	keyInfo, err := client.RotateKey(ctx, time.Hour)
+	assert.NoError(t, err)
+	assert.NotEqual(t, oldKeyID, keyInfo.ID)
+
+	jwsKeySet, err := jwk.Fetch(server.URL + "/.well-known/jwks.json")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, jwsKeySet.Len())
+
+	assert.Error(t, client.RetireKey(ctx, keyInfo.ID))
+	assert.Error(t, client.RetireKey(ctx, "unknown"))
+	assert.NoError(t, client.RetireKey(ctx, oldKeyID))
+
+	jwsKeySet, err = jwk.Fetch(server.URL + "/.well-known/jwks.json")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, jwsKeySet.Len())
+}
+
+func TestClient_RotateKeyRetention(t *testing.T) {
+	server, err := NewServer()
+	assert.NoError(t, err)
+
+	defer server.Close()
+
+	ctx := context.Background()
+	client := jwtmock.NewClient(server.URL)
+
+	// negative durations are rejected
+	_, err = client.RotateKey(ctx, -time.Second)
+	assert.Error(t, err)
+
+	_, err = client.StageKey(ctx, jwtmock.StageRequest{PublishAfterSeconds: -1})
+	assert.Error(t, err)
+
+	for path, body := range map[string]string{
+		"/jwtmock/keys/rotate": `{"retention_seconds": -1}`,
+		"/jwtmock/keys/stage":  `{"publish_after_seconds": -1}`,
+	} {
+		resp, err := http.Post(server.URL+path, "application/json", strings.NewReader(body))
+		if assert.NoError(t, err) {
+			assert.NoError(t, resp.Body.Close())
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, path)
+		}
+	}
+
+	// a sub-second retention is rounded up rather than keeping the previous key forever
+	oldKeyID := server.keystore.GetSigningKey().ID
+
+	_, err = client.RotateKey(ctx, 100*time.Millisecond)
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		jwsKeySet, err := jwk.Fetch(server.URL + "/.well-known/jwks.json")
+		if err != nil || jwsKeySet.Len() != 1 {
+			return false
+		}
+
+		return jwsKeySet.Keys[0].KeyID() != oldKeyID
+	}, 3*time.Second, 100*time.Millisecond)
+}
+
+func TestServer_DeactivateKey(t *testing.T) {
+	server, err := NewServer()
+	assert.NoError(t, err)
+
+	defer server.Close()
+
+	signingKey, err := server.AddKey(jwa.ES256)
+	assert.NoError(t, err)
+
+	claims := jwtmock.Claims{
+		jwt.SubjectKey:    "olg387f",
+		jwt.ExpirationKey: time.Now().Add(time.Hour).Unix(),
+	}
+	token, err := server.GenerateJWT(claims, jwtmock.WithKeyID(signingKey.ID))
+	assert.NoError(t, err)
+
+	assert.Error(t, server.DeactivateKey(server.keystore.GetSigningKey().ID))
+	assert.Error(t, server.DeactivateKey("unknown"))
+	assert.NoError(t, server.DeactivateKey(signingKey.ID))
+
+	// the key no longer signs JWTs but existing JWTs still verify
+	_, err = server.GenerateJWT(claims, jwtmock.WithKeyID(signingKey.ID))
+	assert.Error(t, err)
+
+	jwsKeySet, err := jwk.Fetch(server.URL + "/.well-known/jwks.json")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, jwsKeySet.Len())
+
+	_, err = jwt.Parse(bytes.NewReader([]byte(token)), jwt.WithKeySet(jwsKeySet))
+	assert.NoError(t, err)
+
+	keys := server.ListKeys()
+	assert.Len(t, keys, 2)
+	assert.Equal(t, jwtmock.KeyStatusActive, keys[0].Status)
+	assert.True(t, keys[0].Current)
+	assert.Equal(t, jwtmock.KeyInfo{
+		ID:        signingKey.ID,
+		Algorithm: jwa.ES256.String(),
+		Status:    jwtmock.KeyStatusPublished,
+	}, keys[1])
+
+	// once unpublished, existing JWTs no longer verify
+	assert.NoError(t, server.RetireKey(signingKey.ID))
+	assert.Error(t, server.DeactivateKey(signingKey.ID))
+	assert.Equal(t, jwtmock.KeyStatusRetired, server.ListKeys()[1].Status)
+
+	jwsKeySet, err = jwk.Fetch(server.URL + "/.well-known/jwks.json")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, jwsKeySet.Len())
+
+	_, err = jwt.Parse(bytes.NewReader([]byte(token)), jwt.WithKeySet(jwsKeySet))
+	assert.Error(t, err)
+}
+
+func TestClient_ListKeys(t *testing.T) {
+	server, err := NewServer(WithAdditionalKeys(jwa.EdDSA))
+	assert.NoError(t, err)
+
+	defer server.Close()
+
+	ctx := context.Background()
+	client := jwtmock.NewClient(server.URL)
+
+	keys, err := client.ListKeys(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, server.ListKeys(), keys)
+	assert.Len(t, keys, 2)
+
+	assert.Error(t, client.DeactivateKey(ctx, keys[0].ID))
+	assert.Error(t, client.DeactivateKey(ctx, "unknown"))
+	assert.NoError(t, client.DeactivateKey(ctx, keys[1].ID))
+
+	keys, err = client.ListKeys(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, jwtmock.KeyStatusPublished, keys[1].Status)
+}
+
+func TestServer_StageKey(t *testing.T) {
+	server, err := NewServer()
+	assert.NoError(t, err)
+
+	defer server.Close()
+
+	previousKey := server.keystore.GetSigningKey()
+
+	signingKey, err := server.StageKey("", 0, true)
+	assert.NoError(t, err)
+
+	// the staged key signs JWTs that don't verify against the published JWKS
+	token, err := server.GenerateJWT(jwtmock.Claims{
+		jwt.SubjectKey:    "olg387f",
+		jwt.ExpirationKey: time.Now().Add(time.Hour).Unix(),
+	})
+	assert.NoError(t, err)
+
+	jwsKeySet, err := jwk.Fetch(server.URL + "/.well-known/jwks.json")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, jwsKeySet.Len())
+	assert.Equal(t, previousKey.ID, jwsKeySet.Keys[0].KeyID())
+
+	_, err = jwt.Parse(bytes.NewReader([]byte(token)), jwt.WithKeySet(jwsKeySet))
+	assert.Error(t, err)
+
+	keys := server.ListKeys()
+	assert.Len(t, keys, 2)
+	assert.Equal(t, jwtmock.KeyInfo{
+		ID:        signingKey.ID,
+		Algorithm: jwa.RS256.String(),
+		Status:    jwtmock.KeyStatusStaged,
+		Current:   true,
+	}, keys[1])
+
+	assert.Error(t, server.PublishKey(previousKey.ID))
+	assert.Error(t, server.PublishKey("unknown"))
+	assert.NoError(t, server.PublishKey(signingKey.ID))
+	assert.Error(t, server.PublishKey(signingKey.ID))
+
+	jwsKeySet, err = jwk.Fetch(server.URL + "/.well-known/jwks.json")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, jwsKeySet.Len())
+
+	_, err = jwt.Parse(bytes.NewReader([]byte(token)), jwt.WithKeySet(jwsKeySet))
+	assert.NoError(t, err)
+}
+
+func TestServer_StagedKeyNotPublished(t *testing.T) {
+	server, err := NewServer()
+	assert.NoError(t, err)
+
+	defer server.Close()
+
+	stagedKey, err := server.StageKey("", 0, false)
+	assert.NoError(t, err)
+
+	// staged keys can't be deactivated since that would publish them
+	assert.Error(t, server.DeactivateKey(stagedKey.ID))
+	assert.Equal(t, http.StatusBadRequest,
+		doJSON(t, http.MethodDelete, server.URL+"/jwtmock/keys/"+stagedKey.ID+"/signing", nil))
+
+	// a staged current key is retired when it is rotated rather than published
+	stagedCurrentKey, err := server.StageKey("", 0, true)
+	assert.NoError(t, err)
+
+	_, err = server.RotateKey(0)
+	assert.NoError(t, err)
+
+	jwsKeySet, err := jwk.Fetch(server.URL + "/.well-known/jwks.json")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, jwsKeySet.Len())
+
+	for _, key := range jwsKeySet.Keys {
+		assert.NotEqual(t, stagedKey.ID, key.KeyID())
+		assert.NotEqual(t, stagedCurrentKey.ID, key.KeyID())
+	}
+
+	statuses := map[string]jwtmock.KeyStatus{}
+	for _, key := range server.ListKeys() {
+		statuses[key.ID] = key.Status
+	}
+
+	assert.Equal(t, jwtmock.KeyStatusStaged, statuses[stagedKey.ID])
+	assert.Equal(t, jwtmock.KeyStatusRetired, statuses[stagedCurrentKey.ID])
+}
+
+func TestServer_StagedKeyDuplicateDecoy(t *testing.T) {
+	server, err := NewServer(WithDecoyKeys(jwtmock.DecoyDuplicateKeyID))
+	assert.NoError(t, err)
+
+	defer server.Close()
+
+	publishedKey := server.keystore.GetSigningKey()
+
+	// the duplicate kid decoy copies the published key rather than the staged current key
+	stagedKey, err := server.StageKey("", 0, true)
+	assert.NoError(t, err)
+
+	jwsKeySet, err := jwk.Fetch(server.URL + "/.well-known/jwks.json")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, jwsKeySet.Len())
+
+	for _, key := range jwsKeySet.Keys {
+		assert.NotEqual(t, stagedKey.ID, key.KeyID())
+		assert.Equal(t, publishedKey.ID, key.KeyID())
+	}
+}
+
+func TestClient_StageKey(t *testing.T) {
+	server, err := NewServer()
+	assert.NoError(t, err)
+
+	defer server.Close()
+
+	ctx := context.Background()
+	client := jwtmock.NewClient(server.URL)
+
+	keyInfo, err := client.StageKey(ctx, jwtmock.StageRequest{Algorithm: jwa.ES256.String(), PublishAfterSeconds: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, jwtmock.KeyStatusStaged, keyInfo.Status)
+	assert.False(t, keyInfo.Current)
+
+	// staged keys that are not current sign JWTs by key ID
+	_, err = client.GenerateJWT(ctx, jwtmock.Claims{
+		jwt.SubjectKey:    "olg387f",
+		jwt.ExpirationKey: time.Now().Add(time.Hour).Unix(),
+	}, jwtmock.WithKeyID(keyInfo.ID))
+	assert.NoError(t, err)
+
+	jwsKeySet, err := jwk.Fetch(server.URL + "/.well-known/jwks.json")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, jwsKeySet.Len())
+
+	time.Sleep(time.Second)
+
+	jwsKeySet, err = jwk.Fetch(server.URL + "/.well-known/jwks.json")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, jwsKeySet.Len())
+
+	assert.Error(t, client.PublishKey(ctx, keyInfo.ID))
+
+	keyInfo, err = client.StageKey(ctx, jwtmock.StageRequest{})
+	assert.NoError(t, err)
+	assert.NoError(t, client.PublishKey(ctx, keyInfo.ID))
+}
+
+func TestNewServer_KeyRotation(t *testing.T) {
+	server, err := NewServer(WithKeyRotation(50*time.Millisecond, time.Hour))
+	assert.NoError(t, err)
+
+	initialKeyID := server.keystore.GetSigningKey().ID
+
+	assert.Eventually(t, func() bool {
+		jwsKeySet, err := jwk.Fetch(server.URL + "/.well-known/jwks.json")
+		return err == nil && jwsKeySet.Len() >= 3
+	}, time.Second, 10*time.Millisecond)
+
+	assert.NotEqual(t, initialKeyID, server.keystore.GetSigningKey().ID)
+
+	server.Close()
+
+	// no rotation happens after the server is closed
+	currentKeyID := server.keystore.GetSigningKey().ID
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, currentKeyID, server.keystore.GetSigningKey().ID)
+}
+
+func TestNewServer_KeyRotationDefaultRetention(t *testing.T) {
+	server, err := NewServer(WithKeyRotation(50*time.Millisecond, 0))
+	assert.NoError(t, err)
+
+	defer server.Close()
+
+	initialKeyID := server.keystore.GetSigningKey().ID
+
+	// previous keys are retired after one interval rather than kept forever
+	assert.Eventually(t, func() bool {
+		for _, key := range server.ListKeys() {
+			if key.ID == initialKeyID {
+				return key.Status == jwtmock.KeyStatusRetired
+			}
+		}
+
+		return true
+	}, time.Second, 10*time.Millisecond)
+
+	jwsKeySet, err := jwk.Fetch(server.URL + "/.well-known/jwks.json")
+	assert.NoError(t, err)
+	assert.LessOrEqual(t, jwsKeySet.Len(), 3)
+}
+
+func TestServer_RetiredKeysPruned(t *testing.T) {
+	server, err := NewServer(WithSigningAlgorithm(jwa.ES256))
+	assert.NoError(t, err)
+
+	defer server.Close()
+
+	initialKeyID := server.keystore.GetSigningKey().ID
+
+	for i := 0; i < 20; i++ {
+		_, err = server.RotateKey(time.Nanosecond)
+		assert.NoError(t, err)
+	}
+
+	// only the most recently retired keys are listed
+	keys := server.ListKeys()
+	assert.Len(t, keys, 17)
+
+	for _, key := range keys {
+		assert.NotEqual(t, initialKeyID, key.ID)
+	}
+}
+
+func TestNewServer_CertificateRenewal(t *testing.T) {
+	server, err := NewServer(
+		WithCertificateLifetime(time.Second),
+		WithCertificateRenewal(jwtmock.CertificateRenewalRenew, 800*time.Millisecond),
+	)
+	assert.NoError(t, err)
+
+	defer server.Close()
+
+	initialKey := server.keystore.GetSigningKey()
+	initialNotAfter := initialKey.Certificates[0].NotAfter
+
+	// the same key is published with a new certificate
+	assert.Eventually(t, func() bool {
+		jwsKeySet, err := jwk.Fetch(server.URL + "/.well-known/jwks.json")
+		return err == nil && jwsKeySet.Len() == 1 && jwsKeySet.Keys[0].KeyID() == initialKey.ID &&
+			jwsKeySet.Keys[0].X509CertChain()[0].NotAfter.After(initialNotAfter)
+	}, 2*time.Second, 50*time.Millisecond)
+
+	jwsKeySet, err := jwk.Fetch(server.URL + "/.well-known/jwks.json")
+	assert.NoError(t, err)
+	assert.NoError(t, ValidateKeySet(jwsKeySet))
+
+	token, err := server.GenerateJWT(jwtmock.Claims{
+		jwt.SubjectKey:    "olg387f",
+		jwt.ExpirationKey: time.Now().Add(time.Hour).Unix(),
+	})
+	assert.NoError(t, err)
+
+	_, err = jwt.Parse(bytes.NewReader([]byte(token)), jwt.WithKeySet(jwsKeySet))
+	assert.NoError(t, err)
+}
+
+func TestNewServer_CertificateRenewalFixedTime(t *testing.T) {
+	// certificates issued at a fixed time in the past don't expire by the fixed clock
+	server, err := NewServer(
+		WithSeed(42),
+		WithFixedTime(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)),
+		WithCertificateLifetime(time.Hour),
+		WithCertificateRenewal(jwtmock.CertificateRenewalRotate, 200*time.Millisecond),
+	)
+	assert.NoError(t, err)
+
+	defer server.Close()
+
+	initialKey := server.keystore.GetSigningKey()
+
+	time.Sleep(300 * time.Millisecond)
+
+	keys := server.ListKeys()
+	assert.Len(t, keys, 1)
+	assert.Equal(t, initialKey.ID, keys[0].ID)
+}
+
+func TestNewServer_CertificateRenewalRotate(t *testing.T) {
+	server, err := NewServer(
+		WithCertificateLifetime(time.Second),
+		WithCertificateRenewal(jwtmock.CertificateRenewalRotate, 800*time.Millisecond),
+	)
+	assert.NoError(t, err)
+
+	defer server.Close()
+
+	initialKeyID := server.keystore.GetSigningKey().ID
+
+	// a new key is published and signs JWTs
+	assert.Eventually(t, func() bool {
+		return server.keystore.GetSigningKey().ID != initialKeyID
+	}, 2*time.Second, 50*time.Millisecond)
+
+	jwsKeySet, err := jwk.Fetch(server.URL + "/.well-known/jwks.json")
+	assert.NoError(t, err)
+
+	token, err := server.GenerateJWT(jwtmock.Claims{
+		jwt.SubjectKey:    "olg387f",
+		jwt.ExpirationKey: time.Now().Add(time.Hour).Unix(),
+	})
+	assert.NoError(t, err)
+
+	_, err = jwt.Parse(bytes.NewReader([]byte(token)), jwt.WithKeySet(jwsKeySet))
+	assert.NoError(t, err)
+}
+
+func TestNewServer_CertificateRenewalRotateAlgorithm(t *testing.T) {
+	server, err := NewServer(
+		WithCertificateLifetime(time.Second),
+		WithCertificateRenewal(jwtmock.CertificateRenewalRotate, 800*time.Millisecond),
+	)
+	assert.NoError(t, err)
+
+	defer server.Close()
+
+	initialKeyID := server.keystore.GetSigningKey().ID
+
+	// the current key is replaced by a key for its own algorithm rather than the default algorithm
+	currentKey, err := server.StageKey(jwa.ES256, 0, true)
+	assert.NoError(t, err)
+	assert.NoError(t, server.PublishKey(currentKey.ID))
+
+	assert.Eventually(t, func() bool {
+		return server.keystore.GetSigningKey().ID != currentKey.ID
+	}, 2*time.Second, 50*time.Millisecond)
+
+	assert.Equal(t, jwa.ES256, server.keystore.GetSigningKey().Algorithm)
+
+	statuses := map[string]jwtmock.KeyStatus{}
+	for _, key := range server.ListKeys() {
+		statuses[key.ID] = key.Status
+	}
+
+	assert.Equal(t, jwtmock.KeyStatusRetired, statuses[initialKeyID])
+}
+
+func TestNewServer_UnsupportedCertificateRenewal(t *testing.T) {
+	_, err := NewServer(WithCertificateRenewal("replace", time.Hour))
+	assert.Error(t, err)
+}
+
+func TestNewServer_Seed(t *testing.T) {
+	fixedTime := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	claims := jwtmock.Claims{
+		jwt.SubjectKey:    "olg387f",
+		jwt.IssuedAtKey:   fixedTime.Unix(),
+		jwt.ExpirationKey: time.Date(2100, time.January, 1, 0, 0, 0, 0, time.UTC).Unix(),
+	}
+
+	for _, alg := range []jwa.SignatureAlgorithm{jwa.RS256, jwa.ES256, jwa.EdDSA, jwa.HS256} {
+		alg := alg
+		t.Run(alg.String(), func(t *testing.T) {
+			var keySets []json.RawMessage
+			var tokens []string
+			for _, seed := range []int64{42, 42, 7} {
+				server, err := NewServer(WithSigningAlgorithm(alg), WithSeed(seed), WithFixedTime(fixedTime),
+					WithSymmetricKeyPublishing())
+				assert.NoError(t, err)
+
+				var keySet json.RawMessage
+				assert.Equal(t, http.StatusOK, doJSON(t, http.MethodGet, server.URL+"/.well-known/jwks.json", &keySet))
+				keySets = append(keySets, keySet)
+
+				jwsKeySet, err := jwk.ParseBytes(keySet)
+				assert.NoError(t, err)
+				assert.NoError(t, ValidateKeySet(jwsKeySet))
+
+				token, err := server.GenerateJWT(claims)
+				assert.NoError(t, err)
+				tokens = append(tokens, token)
+
+				server.Close()
+			}
+
+			assert.JSONEq(t, string(keySets[0]), string(keySets[1]))
+			assert.NotEqual(t, string(keySets[0]), string(keySets[2]))
+
+			// ECDSA signatures are always randomized
+			if alg != jwa.ES256 {
+				assert.Equal(t, tokens[0], tokens[1])
+			}
+		})
+	}
+}
+
+func TestNewServer_SeedDefaultClaims(t *testing.T) {
+	fixedTime := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	var tokens []string
+	for i := 0; i < 2; i++ {
+		server, err := NewServer(WithSeed(42), WithFixedTime(fixedTime))
+		assert.NoError(t, err)
+
+		// default claims are relative to the fixed time and are validated against it
+		token, err := server.GenerateJWT(jwtmock.Claims{jwt.SubjectKey: "olg387f"})
+		assert.NoError(t, err)
+		tokens = append(tokens, token)
+
+		_, err = jwtmock.NewClient(server.URL).GenerateJWT(context.Background(), jwtmock.Claims{jwt.SubjectKey: "olg387f"})
+		assert.NoError(t, err)
+
+		server.Close()
+	}
+
+	assert.Equal(t, tokens[0], tokens[1])
+
+	parsedToken, err := jwt.Parse(bytes.NewReader([]byte(tokens[0])))
+	if assert.NoError(t, err) {
+		assert.Equal(t, fixedTime, parsedToken.IssuedAt().UTC())
+		assert.Equal(t, fixedTime, parsedToken.NotBefore().UTC())
+		assert.Equal(t, fixedTime.Add(jwtmock.DefaultTokenTTL), parsedToken.Expiration().UTC())
+	}
+}
+
+func TestNewServer_ThumbprintKeyID(t *testing.T) {
+	server, err := NewServer(WithKeyIDStrategy(jwtmock.KeyIDThumbprint), WithAdditionalKeys(jwa.ES256, jwa.EdDSA))
+	assert.NoError(t, err)
+
+	defer server.Close()
+
+	jwsKeySet, err := jwk.Fetch(server.URL + "/.well-known/jwks.json")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, jwsKeySet.Len())
+
+	for _, key := range jwsKeySet.Keys {
+		thumbprint, err := key.Thumbprint(crypto.SHA256)
+		assert.NoError(t, err)
+		assert.Equal(t, base64.RawURLEncoding.EncodeToString(thumbprint), key.KeyID())
+	}
+}
+
+func TestNewServer_SharedKey(t *testing.T) {
+	var keys []jwk.Key
+	for i := 0; i < 2; i++ {
+		server, err := NewServer(WithSharedKey(), WithSigningAlgorithm(jwa.ES256))
+		assert.NoError(t, err)
+
+		defer server.Close()
+
+		jwsKeySet, err := jwk.Fetch(server.URL + "/.well-known/jwks.json")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, jwsKeySet.Len())
+
+		keys = append(keys, jwsKeySet.Keys[0])
+	}
+
+	assert.Equal(t, keys[0].KeyID(), keys[1].KeyID())
+
+	thumbprints := make([][]byte, len(keys))
+	for i, key := range keys {
+		thumbprint, err := key.Thumbprint(crypto.SHA256)
+		assert.NoError(t, err)
+
+		thumbprints[i] = thumbprint
+	}
+
+	assert.Equal(t, thumbprints[0], thumbprints[1])
+
+	// certificates are not shared
+	assert.NotEqual(t, keys[0].X509CertChain()[0].Raw, keys[1].X509CertChain()[0].Raw)
+
+	// the key ID strategy applies to the shared key
+	server, err := NewServer(WithSharedKey(), WithSigningAlgorithm(jwa.ES256),
+		WithKeyIDStrategy(jwtmock.KeyIDThumbprint), WithKeyIDPrefix("mock-"))
+	assert.NoError(t, err)
+
+	defer server.Close()
+
+	jwsKeySet, err := jwk.Fetch(server.URL + "/.well-known/jwks.json")
+	assert.NoError(t, err)
+	assert.Equal(t, "mock-"+base64.RawURLEncoding.EncodeToString(thumbprints[0]), jwsKeySet.Keys[0].KeyID())
+}
+
+func TestNewServer_KeyIDPrefix(t *testing.T) {
+	server, err := NewServer(WithKeyIDPrefix("mock-"))
+	assert.NoError(t, err)
+
+	defer server.Close()
+
+	signingKey, err := server.RotateKey(0)
+	assert.NoError(t, err)
+	assert.Regexp(t, "^mock-[a-zA-Z0-9]{16}$", signingKey.ID)
+
+	jwsKeySet, err := jwk.Fetch(server.URL + "/.well-known/jwks.json")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, jwsKeySet.Len())
+
+	for _, key := range jwsKeySet.Keys {
+		assert.Regexp(t, "^mock-", key.KeyID())
+	}
+}
+
+func TestNewServer_FixedKeyID(t *testing.T) {
+	server, err := NewServer(WithFixedKeyID("my-key"))
+	assert.NoError(t, err)
+
+	defer server.Close()
+
+	token, err := server.GenerateJWT(jwtmock.Claims{
+		jwt.SubjectKey:    "olg387f",
+		jwt.ExpirationKey: time.Now().Add(time.Hour).Unix(),
+	})
+	assert.NoError(t, err)
+
+	msg, err := jws.ParseString(token)
+	assert.NoError(t, err)
+	assert.Equal(t, "my-key", msg.Signatures()[0].ProtectedHeaders().KeyID())
+
+	// the key ID cannot be used by two keys at once - rotation retires the previous key
+	_, err = server.AddKey(jwa.ES256)
+	assert.Error(t, err)
+
+	signingKey, err := server.RotateKey(0)
+	assert.NoError(t, err)
+	assert.Equal(t, "my-key", signingKey.ID)
+
+	rotatedToken, err := server.GenerateJWT(jwtmock.Claims{
+		jwt.SubjectKey:    "olg387f",
+		jwt.ExpirationKey: time.Now().Add(time.Hour).Unix(),
+	})
+	assert.NoError(t, err)
+
+	jwsKeySet, err := jwk.Fetch(server.URL + "/.well-known/jwks.json")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, jwsKeySet.Len())
+	assert.Equal(t, "my-key", jwsKeySet.Keys[0].KeyID())
+
+	_, err = jwt.Parse(bytes.NewReader([]byte(token)), jwt.WithKeySet(jwsKeySet))
+	assert.Error(t, err)
+
+	_, err = jwt.Parse(bytes.NewReader([]byte(rotatedToken)), jwt.WithKeySet(jwsKeySet))
+	assert.NoError(t, err)
+
+	keys := server.ListKeys()
+	if assert.Len(t, keys, 2) {
+		assert.Equal(t, jwtmock.KeyStatusRetired, keys[0].Status)
+		assert.Equal(t, jwtmock.KeyStatusActive, keys[1].Status)
+		assert.True(t, keys[1].Current)
+	}
+}
+
+func TestNewServer_UnsupportedKeyIDStrategy(t *testing.T) {
+	_, err := NewServer(WithKeyIDStrategy("sequential"))
+	assert.Error(t, err)
+
+	_, err = NewServer(WithFixedKeyID(""))
+	assert.Error(t, err)
+}
+
+func TestNewServer_SigningKeyPEM(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	assert.NoError(t, err)
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	assert.NoError(t, err)
+
+	rsaKeyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)})
+	rsaCertPEM := selfSignedCertPEM(t, rsaKey)
+
+	newServer := func() *Server {
+		server, err := NewServer(
+			WithSigningKeyPEM(rsaKeyPEM, rsaCertPEM),
+			WithSigningKeyPEM(privateKeyPEM(t, edKey), nil),
+			WithSigningKeyPEM(privateKeyPEM(t, ecKey), selfSignedCertPEM(t, ecKey)),
+		)
+		assert.NoError(t, err)
+
+		return server
+	}
+
+	server := newServer()
+	defer server.Close()
+
+	jwsKeySet, err := jwk.Fetch(server.URL + "/.well-known/jwks.json")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, jwsKeySet.Len())
+	assert.Equal(t, jwa.RS256.String(), jwsKeySet.Keys[0].Algorithm())
+	assert.Equal(t, jwa.EdDSA.String(), jwsKeySet.Keys[1].Algorithm())
+	assert.Equal(t, jwa.ES384.String(), jwsKeySet.Keys[2].Algorithm())
+
+	certBlock, _ := pem.Decode(rsaCertPEM)
+	assert.Equal(t, certBlock.Bytes, jwsKeySet.Keys[0].X509CertChain()[0].Raw)
+
+	now := time.Now()
+	token, err := server.GenerateJWT(jwtmock.Claims{
+		jwt.SubjectKey:    "olg387f",
+		jwt.IssuedAtKey:   now.Unix(),
+		jwt.ExpirationKey: now.Add(time.Hour).Unix(),
+	})
+	assert.NoError(t, err)
+
+	// tokens are still valid for a server restarted with the same keys
+	restartedServer := newServer()
+	defer restartedServer.Close()
+
+	restartedKeySet, err := jwk.Fetch(restartedServer.URL + "/.well-known/jwks.json")
+	assert.NoError(t, err)
+
+	_, err = jwt.Parse(bytes.NewReader([]byte(token)), jwt.WithKeySet(restartedKeySet))
+	assert.NoError(t, err)
+}
+
+func TestNewServer_SigningKeyPEMMismatch(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	assert.NoError(t, err)
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	assert.NoError(t, err)
+
+	_, err = NewServer(WithSigningKeyPEM(privateKeyPEM(t, rsaKey), selfSignedCertPEM(t, otherKey)))
+	assert.Error(t, err)
+
+	_, err = NewServer(WithSigningKeyPEM([]byte("not a key"), nil))
+	assert.Error(t, err)
+}
+
+// privateKeyPEM encodes the private key as PKCS #8 PEM.
+func privateKeyPEM(t *testing.T, key interface{}) []byte {
+	t.Helper()
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.NoError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+// selfSignedCertPEM creates a PEM-encoded self-signed certificate for the key.
+func selfSignedCertPEM(t *testing.T, key crypto.Signer) []byte {
+	t.Helper()
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{Organization: []string{"Test"}},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	assert.NoError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestServer_ExportSigningKey(t *testing.T) {
+	server, err := NewServer(WithPrivateKeyExport(), WithSigningAlgorithm(jwa.ES256))
+	assert.NoError(t, err)
+
+	defer server.Close()
+
+	export, err := server.ExportSigningKey()
+	assert.NoError(t, err)
+	assert.Equal(t, "ES256", export.Algorithm)
+
+	client := jwtmock.NewClient(server.URL)
+
+	clientExport, err := client.ExportSigningKey(context.Background(), "")
+	assert.NoError(t, err)
+	assert.Equal(t, export, clientExport)
+
+	// other keys are exported by ID
+	additionalKey, err := server.AddKey(jwa.EdDSA)
+	assert.NoError(t, err)
+
+	clientExport, err = client.ExportSigningKey(context.Background(), additionalKey.ID)
+	if assert.NoError(t, err) {
+		assert.Equal(t, additionalKey.ID, clientExport.ID)
+		assert.Equal(t, "EdDSA", clientExport.Algorithm)
+	}
+
+	_, err = client.ExportSigningKey(context.Background(), "unknown")
+	assert.Error(t, err)
+
+	jwsKeySet, err := jwk.Fetch(server.URL + "/.well-known/jwks.json")
+	assert.NoError(t, err)
+
+	claims := jwtmock.Claims{
+		jwt.SubjectKey:    "olg387f",
+		jwt.IssuedAtKey:   time.Now().Unix(),
+		jwt.ExpirationKey: time.Now().Add(time.Hour).Unix(),
+	}
+
+	// sign outside the server using the PEM key
+	block, _ := pem.Decode([]byte(export.PEM))
+	pemKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	assert.NoError(t, err)
+
+	token, err := claims.CreateJWT(&jwtmock.SigningKey{ID: export.ID, Key: pemKey, Algorithm: jwa.ES256})
+	assert.NoError(t, err)
+
+	_, err = jwt.Parse(bytes.NewReader([]byte(token)), jwt.WithKeySet(jwsKeySet))
+	assert.NoError(t, err)
+
+	// sign outside the server using the private JWK
+	privateJWK, err := jwk.ParseKey(export.JWK)
+	assert.NoError(t, err)
+	assert.Equal(t, export.ID, privateJWK.KeyID())
+
+	var jwkKey ecdsa.PrivateKey
+	assert.NoError(t, privateJWK.Raw(&jwkKey))
+
+	token, err = claims.CreateJWT(&jwtmock.SigningKey{ID: export.ID, Key: &jwkKey, Algorithm: jwa.ES256})
+	assert.NoError(t, err)
+
+	_, err = jwt.Parse(bytes.NewReader([]byte(token)), jwt.WithKeySet(jwsKeySet))
+	assert.NoError(t, err)
+}
+
+func TestServer_ExportSigningKeyDisabled(t *testing.T) {
+	server, err := NewServer()
+	assert.NoError(t, err)
+
+	defer server.Close()
+
+	_, err = server.ExportSigningKey()
+	assert.ErrorIs(t, err, ErrExportDisabled)
+
+	_, err = jwtmock.NewClient(server.URL).ExportSigningKey(context.Background(), "")
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusNotFound, doJSON(t, http.MethodGet, server.URL+"/jwtmock/signing-key", nil))
+}
+
+func BenchmarkNewServer(b *testing.B) {
+	benchmarks := []struct {
+		name    string
+		options []ServerOption
+		idle    time.Duration // time spent by the test between creating servers - not measured
+	}{
+		{name: "OnDemand"},
+		{name: "KeyPool", options: []ServerOption{WithKeyPool()}},
+		{name: "KeyPoolIdle", options: []ServerOption{WithKeyPool()}, idle: 100 * time.Millisecond},
+		{name: "SharedKey", options: []ServerOption{WithSharedKey()}},
+	}
+
+	for _, bm := range benchmarks {
+		bm := bm
+
+		b.Run(bm.name, func(b *testing.B) {
+			options := append([]ServerOption{WithKeyLength(2048)}, bm.options...)
+			for i := 0; i < b.N; i++ {
+				server, err := NewServer(options...)
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				server.Close()
+
+				b.StopTimer()
+				time.Sleep(bm.idle)
+				b.StartTimer()
+			}
+		})
+	}
 }
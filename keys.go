@@ -0,0 +1,112 @@
+package jwtmock
+
+import "encoding/json"
+
+// KeyStatus describes how a key is used by the server.
+type KeyStatus string
+
+const (
+	// KeyStatusActive means the key signs JWTs and is published in the JWKS.
+	KeyStatusActive KeyStatus = "active"
+
+	// KeyStatusPublished means the key is published in the JWKS but no longer signs JWTs (e.g. after rotation).
+	KeyStatusPublished KeyStatus = "published-only"
+
+	// KeyStatusRetired means the key is neither published nor signs JWTs.
+	KeyStatusRetired KeyStatus = "retired"
+
+	// KeyStatusStaged means the key signs JWTs but is not published in the JWKS yet.
+	KeyStatusStaged KeyStatus = "staged"
+)
+
+// KeyRequest is a request to add a new signing key.
+type KeyRequest struct {
+	Algorithm string `json:"alg,omitempty"`
+}
+
+// RotateRequest is a request to rotate the current signing key.
+type RotateRequest struct {
+	// RetentionSeconds is how long the previous key stays published - the server default is used if nil
+	// and zero keeps it published until explicitly retired.
+	RetentionSeconds *int64 `json:"retention_seconds,omitempty"`
+}
+
+// StageRequest is a request to add a signing key that is withheld from the JWKS until it is published.
+type StageRequest struct {
+	Algorithm string `json:"alg,omitempty"`
+
+	// PublishAfterSeconds is the delay after which the key is published - zero keeps it staged until explicitly
+	// published.
+	PublishAfterSeconds int64 `json:"publish_after_seconds,omitempty"`
+
+	// Current makes the staged key the current signing key - the previous key stays published as if rotated.
+	Current bool `json:"current,omitempty"`
+}
+
+// KeyInfo describes a signing key held by the server.
+type KeyInfo struct {
+	ID        string    `json:"kid"`
+	Algorithm string    `json:"alg"`
+	Status    KeyStatus `json:"status,omitempty"`
+
+	// Current is true for the current (default) signing key.
+	Current bool `json:"current,omitempty"`
+}
+
+// PrivateKeyExport is the private signing material of a key - only available when explicitly enabled.
+type PrivateKeyExport struct {
+	ID        string `json:"kid"`
+	Algorithm string `json:"alg"`
+
+	// PEM is the PKCS #8 encoded private key - empty for symmetric keys.
+	PEM string `json:"pem,omitempty"`
+
+	// JWK is the private key as a JSON web key.
+	JWK json.RawMessage `json:"jwk"`
+}
+
+// CertificateRenewal describes what the server does when the certificate of a generated key is about to expire.
+type CertificateRenewal string
+
+const (
+	// CertificateRenewalNone means nothing is done - expired certificates stay published.
+	CertificateRenewalNone CertificateRenewal = ""
+
+	// CertificateRenewalRotate means keys are rotated (replaced by new keys) ahead of certificate expiry.
+	CertificateRenewalRotate CertificateRenewal = "rotate"
+
+	// CertificateRenewalRenew means a new certificate is issued for the same key ahead of certificate expiry.
+	CertificateRenewalRenew CertificateRenewal = "renew"
+)
+
+// KeyIDStrategy describes how the key ID (kid) of a generated key is chosen.
+type KeyIDStrategy string
+
+const (
+	// KeyIDRandom means key IDs are random strings.
+	KeyIDRandom KeyIDStrategy = "random"
+
+	// KeyIDThumbprint means key IDs are the base64url-encoded RFC 7638 SHA-256 thumbprint of the key.
+	KeyIDThumbprint KeyIDStrategy = "thumbprint"
+
+	// KeyIDFixed means the key ID is a fixed value - only one key can use it at a time, so rotation retires the
+	// previous key.
+	KeyIDFixed KeyIDStrategy = "fixed"
+)
+
+// DecoyKey describes an entry published in the JWKS alongside the signing keys which consumers must ignore.
+type DecoyKey string
+
+const (
+	// DecoyEncryption is a key meant for encryption ("use": "enc").
+	DecoyEncryption DecoyKey = "enc"
+
+	// DecoyUnknownKeyType is a key with a key type ("kty") consumers don't understand.
+	DecoyUnknownKeyType DecoyKey = "unknown-kty"
+
+	// DecoyMissingAlgorithm is a signing key without an algorithm ("alg") that never signs JWTs.
+	DecoyMissingAlgorithm DecoyKey = "no-alg"
+
+	// DecoyDuplicateKeyID is a key of another key type that uses the key ID of the current signing key.
+	DecoyDuplicateKeyID DecoyKey = "duplicate-kid"
+)
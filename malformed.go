@@ -0,0 +1,227 @@
+package jwtmock
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jws"
+)
+
+// ErrUnsupportedMalformedToken means the malformed token variant is not supported.
+var ErrUnsupportedMalformedToken = errors.New("unsupported malformed token")
+
+// MalformedToken names a malformed or maliciously-crafted JWT variant that consumers must reject.
+type MalformedToken string
+
+const (
+	// MalformedAlgNone is an unsigned JWT with the "none" algorithm.
+	MalformedAlgNone MalformedToken = "alg-none"
+
+	// MalformedSignatureStripped is a JWT with the signature removed.
+	MalformedSignatureStripped MalformedToken = "signature-stripped"
+
+	// MalformedForeignKey is a JWT signed by a key the server never published - using the key ID of the signing key.
+	MalformedForeignKey MalformedToken = "foreign-key"
+
+	// MalformedWrongKeyID is a JWT signed by the signing key with a key ID that is not published.
+	MalformedWrongKeyID MalformedToken = "wrong-kid"
+
+	// MalformedMissingKeyID is a JWT signed by the signing key without a key ID.
+	MalformedMissingKeyID MalformedToken = "missing-kid"
+
+	// MalformedTruncated is a JWT with the last segment removed.
+	MalformedTruncated MalformedToken = "truncated"
+
+	// MalformedInvalidBase64 is a JWT whose payload is base64 rather than base64url encoded (signed as is).
+	MalformedInvalidBase64 MalformedToken = "invalid-base64"
+
+	// MalformedDuplicateClaims is a JWT whose payload contains the "sub" claim twice with different values.
+	MalformedDuplicateClaims MalformedToken = "duplicate-claims"
+
+	// MalformedOversizedHeader is a JWT with a header padded beyond common size limits.
+	MalformedOversizedHeader MalformedToken = "oversized-header"
+)
+
+const (
+	// wrongKeyID is the key ID of wrong key ID JWTs
+	wrongKeyID = "jwtmock-unknown-kid"
+
+	// oversized headers are padded with this many bytes
+	headerPadding    = 64 * 1024
+	headerPaddingKey = "x-jwtmock-padding"
+)
+
+// MalformedTokens are all malformed token variants.
+var MalformedTokens = []MalformedToken{
+	MalformedAlgNone,
+	MalformedSignatureStripped,
+	MalformedForeignKey,
+	MalformedWrongKeyID,
+	MalformedMissingKeyID,
+	MalformedTruncated,
+	MalformedInvalidBase64,
+	MalformedDuplicateClaims,
+	MalformedOversizedHeader,
+}
+
+// CreateMalformedJWT generates the given malformed JWT variant from the claims - using the signing key wherever the
+// variant is signed. Claims are not validated. Token options are used to set the "jku", "x5u" and "x5c" headers.
+func (c Claims) CreateMalformedJWT(variant MalformedToken, signingKey *SigningKey,
+	options ...TokenOption) (string, error) {
+	if !variant.supported() {
+		return "", fmt.Errorf("%w: %v", ErrUnsupportedMalformedToken, variant)
+	}
+
+	header, err := malformedHeader(variant, signingKey, NewTokenOptions(options...))
+	if err != nil {
+		return "", err
+	}
+
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", fmt.Errorf("marshal header: %w", err)
+	}
+
+	payloadSegment, err := malformedPayload(variant, c)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + payloadSegment
+
+	switch variant {
+	case MalformedAlgNone, MalformedSignatureStripped:
+		return signingInput + ".", nil
+	case MalformedTruncated:
+		return signingInput, nil
+	}
+
+	key := signingKey.Key
+	if variant == MalformedForeignKey {
+		if key, err = foreignKey(signingKey.Key); err != nil {
+			return "", fmt.Errorf("foreign key: %w", err)
+		}
+	}
+
+	return signInput(signingInput, signingKey.Algorithm, key)
+}
+
+// supported returns true if the variant is one of MalformedTokens.
+func (m MalformedToken) supported() bool {
+	for _, variant := range MalformedTokens {
+		if m == variant {
+			return true
+		}
+	}
+
+	return false
+}
+
+// malformedHeader returns the JOSE header of the malformed JWT variant.
+func malformedHeader(variant MalformedToken, signingKey *SigningKey,
+	opts *TokenOptions) (map[string]interface{}, error) {
+	header, err := newHeader(signingKey, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	switch variant {
+	case MalformedAlgNone:
+		header[jws.AlgorithmKey] = jwa.NoSignature
+	case MalformedWrongKeyID:
+		header[jws.KeyIDKey] = wrongKeyID
+	case MalformedMissingKeyID:
+		delete(header, jws.KeyIDKey)
+	case MalformedOversizedHeader:
+		header[headerPaddingKey] = strings.Repeat("a", headerPadding)
+	}
+
+	return header, nil
+}
+
+// malformedPayload returns the encoded payload segment of the malformed JWT variant.
+func malformedPayload(variant MalformedToken, c Claims) (string, error) {
+	payload, err := json.Marshal(c)
+	if err != nil {
+		return "", fmt.Errorf("marshal claims: %w", err)
+	}
+
+	switch variant {
+	case MalformedInvalidBase64:
+		// padding makes sure the segment is not valid base64url
+		if len(payload)%3 == 0 {
+			payload = append(payload, ' ')
+		}
+
+		return base64.StdEncoding.EncodeToString(payload), nil
+	case MalformedDuplicateClaims:
+		if payload, err = duplicateSubject(c); err != nil {
+			return "", err
+		}
+	}
+
+	return base64.RawURLEncoding.EncodeToString(payload), nil
+}
+
+// duplicateSubject returns the JSON encoding of the claims with the "sub" claim twice - the original value first.
+func duplicateSubject(c Claims) ([]byte, error) {
+	others := make(Claims, len(c))
+	for k, v := range c {
+		if k != "sub" {
+			others[k] = v
+		}
+	}
+
+	sub, _ := c["sub"].(string)
+	first, err := json.Marshal(sub)
+	if err != nil {
+		return nil, fmt.Errorf("marshal claims: %w", err)
+	}
+
+	second, err := json.Marshal(sub + "-duplicate")
+	if err != nil {
+		return nil, fmt.Errorf("marshal claims: %w", err)
+	}
+
+	othersJSON, err := json.Marshal(others)
+	if err != nil {
+		return nil, fmt.Errorf("marshal claims: %w", err)
+	}
+
+	payload := fmt.Sprintf(`{"sub":%s,"sub":%s`, first, second)
+	if len(others) > 0 {
+		payload += "," + string(othersJSON[1:])
+	} else {
+		payload += "}"
+	}
+
+	return []byte(payload), nil
+}
+
+// foreignKey generates a key of the same type and size as the given key.
+func foreignKey(key interface{}) (interface{}, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return rsa.GenerateKey(rand.Reader, k.N.BitLen())
+	case *ecdsa.PrivateKey:
+		return ecdsa.GenerateKey(k.Curve, rand.Reader)
+	case ed25519.PrivateKey:
+		_, foreign, err := ed25519.GenerateKey(rand.Reader)
+		return foreign, err
+	case []byte:
+		foreign := make([]byte, len(k))
+		_, err := rand.Read(foreign)
+
+		return foreign, err
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+}
@@ -0,0 +1,152 @@
+package jwtmock
+
+import (
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwa"
+)
+
+// Headers represents JOSE header parameters of a JWT - a nil value suppresses the header parameter.
+type Headers map[string]interface{}
+
+// JWTRequest is the request body used to generate a JWT with custom headers (which may be left out) - a request body
+// without "claims" and "headers" members is just the claims.
+type JWTRequest struct {
+	Claims  Claims  `json:"claims"`
+	Headers Headers `json:"headers"`
+}
+
+// TokenOptions holds options used when generating a JWT.
+type TokenOptions struct {
+	// KeyID selects the signing key with this ID.
+	KeyID string
+
+	// Algorithm selects a signing key using this algorithm (ignored if KeyID is set).
+	Algorithm jwa.SignatureAlgorithm
+
+	// KeySetURL is set as the "jku" header - the URL of a JWKS containing the signing key.
+	KeySetURL string
+
+	// CertificateURL is set as the "x5u" header - the URL of the PEM-encoded certificate chain of the signing key.
+	CertificateURL string
+
+	// CertificateChain includes the certificate chain of the signing key as the "x5c" header.
+	CertificateChain bool
+
+	// Unchecked signs the claims as they are - even if the JWT is expired, not yet valid, has no subject or claims
+	// have the wrong type.
+	Unchecked bool
+
+	// Malformed generates the malformed JWT variant instead of a valid JWT - claims are not validated.
+	Malformed MalformedToken
+
+	// ConfusionEncoding generates an algorithm confusion JWT signed with the public key in this encoding as the HMAC
+	// secret - claims are not validated.
+	ConfusionEncoding PublicKeyEncoding
+
+	// ConfusionAlgorithm is the HMAC algorithm of algorithm confusion JWTs - HS256 if empty.
+	ConfusionAlgorithm jwa.SignatureAlgorithm
+
+	// Headers are set in the JOSE header of the JWT - overriding (or, if nil, suppressing) the default headers.
+	Headers Headers
+
+	// Now is the clock claims are validated against - the system clock if nil.
+	Now func() time.Time
+}
+
+// TokenOption allows setting options when generating a JWT.
+type TokenOption func(*TokenOptions)
+
+// NewTokenOptions creates token options with the given options applied.
+func NewTokenOptions(options ...TokenOption) *TokenOptions {
+	o := &TokenOptions{}
+	for _, option := range options {
+		option(o)
+	}
+
+	return o
+}
+
+// WithKeyID option is used to sign a JWT with the key that has the given key ID.
+func WithKeyID(kid string) TokenOption {
+	return func(o *TokenOptions) {
+		o.KeyID = kid
+	}
+}
+
+// WithAlgorithm option is used to sign a JWT with a key that uses the given algorithm.
+func WithAlgorithm(alg jwa.SignatureAlgorithm) TokenOption {
+	return func(o *TokenOptions) {
+		o.Algorithm = alg
+	}
+}
+
+// WithKeySetURL option is used to set the "jku" header of a JWT to the given URL - use KeySetURL on the client or
+// test server for a URL that resolves to a JWKS with the signing key.
+func WithKeySetURL(jku string) TokenOption {
+	return func(o *TokenOptions) {
+		o.KeySetURL = jku
+	}
+}
+
+// WithCertificateURL option is used to set the "x5u" header of a JWT to the given URL - use CertificateChainURL on
+// the client or test server for a URL that resolves to the certificate chain of the signing key.
+func WithCertificateURL(x5u string) TokenOption {
+	return func(o *TokenOptions) {
+		o.CertificateURL = x5u
+	}
+}
+
+// WithCertificateChain option is used to include the certificate chain of the signing key in the "x5c" header.
+func WithCertificateChain() TokenOption {
+	return func(o *TokenOptions) {
+		o.CertificateChain = true
+	}
+}
+
+// WithUncheckedClaims option is used to sign claims without validating them - e.g. to mint expired JWTs, JWTs issued
+// in the future, without a subject or with wrong-typed claims (e.g. a string "exp") for negative tests.
+func WithUncheckedClaims() TokenOption {
+	return func(o *TokenOptions) {
+		o.Unchecked = true
+	}
+}
+
+// WithMalformedToken option is used to generate a malformed or maliciously-crafted JWT variant from the claims instead
+// of a valid JWT - see MalformedTokens for all variants.
+func WithMalformedToken(variant MalformedToken) TokenOption {
+	return func(o *TokenOptions) {
+		o.Malformed = variant
+	}
+}
+
+// WithAlgorithmConfusion option is used to generate an algorithm confusion JWT instead of a valid JWT - it is signed
+// with the HMAC algorithm (HS256 if empty) using the public key of the signing key in the given encoding as the secret.
+func WithAlgorithmConfusion(alg jwa.SignatureAlgorithm, encoding PublicKeyEncoding) TokenOption {
+	return func(o *TokenOptions) {
+		o.ConfusionAlgorithm = alg
+		o.ConfusionEncoding = encoding
+	}
+}
+
+// WithHeaders option is used to set custom JOSE header parameters (e.g. "typ", "cty", "crit" or private headers) on a
+// JWT - these override the default headers and a nil value suppresses the header parameter (e.g. "kid").
+func WithHeaders(headers Headers) TokenOption {
+	return func(o *TokenOptions) {
+		if o.Headers == nil {
+			o.Headers = Headers{}
+		}
+
+		for k, v := range headers {
+			o.Headers[k] = v
+		}
+	}
+}
+
+// WithClock option is used to validate claims against the given clock instead of the system clock - e.g. the fixed
+// time of a test server, so that claims defaulted from it are valid. The client ignores this option.
+func WithClock(now func() time.Time) TokenOption {
+	return func(o *TokenOptions) {
+		o.Now = now
+	}
+}